@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefix отличает ключи API от JWT в заголовке Authorization
+const apiKeyPrefix = "rps_"
+
+// APIKey - сведения о ключе API; сам ключ показывается только один раз при выпуске
+type APIKey struct {
+	ID         int64    `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"` // начало ключа, чтобы отличать ключи в списке
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"created_at"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	RevokedAt  string   `json:"revoked_at,omitempty"`
+}
+
+type APIKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`     // пусто - все права владельца
+	ExpiresIn string   `json:"expires_in"` // например "720h"; пусто - бессрочный
+}
+
+type TokenRequest struct {
+	Scopes []string `json:"scopes"` // пусто - все права владельца
+	TTL    string   `json:"ttl"`    // например "1h"; пусто - RPS_TOKEN_TTL
+}
+
+// Хранилище ключей API и отозванных токенов
+
+func createAPIKey(ctx context.Context, userID int64, name, prefix, keyHash string, scopes []string, expiresIn time.Duration) (int64, error) {
+	query := `
+		INSERT INTO api_keys (user_id, name, key_prefix, key_hash, scopes, expires_at)
+		VALUES (?, ?, ?, ?, ?, IF(? > 0, DATE_ADD(NOW(), INTERVAL ? SECOND), NULL))`
+	ctx, span := startDBSpan(ctx, "createAPIKey", query)
+	defer span.End()
+
+	seconds := int64(expiresIn / time.Second)
+	res, err := db.ExecContext(ctx, query, userID, name, prefix, keyHash, strings.Join(scopes, " "), seconds, seconds)
+	if err != nil {
+		return 0, storeError(ctx, "createAPIKey", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, storeError(ctx, "createAPIKey", err)
+	}
+	return id, nil
+}
+
+// getAPIKeyUser возвращает владельца действующего ключа, права ключа и ID ключа
+func getAPIKeyUser(ctx context.Context, keyHash string) (*User, []string, int64, error) {
+	query := `
+		SELECT k.id, k.scopes, u.id, u.username, u.role
+		FROM api_keys k JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = ? AND k.revoked_at IS NULL
+			AND (k.expires_at IS NULL OR k.expires_at > NOW())`
+	ctx, span := startDBSpan(ctx, "getAPIKeyUser", query)
+	defer span.End()
+
+	var keyID int64
+	var scopes string
+	var u User
+	err := db.QueryRowContext(ctx, query, keyHash).Scan(&keyID, &scopes, &u.ID, &u.Username, &u.Role)
+	if err != nil {
+		return nil, nil, 0, storeError(ctx, "getAPIKeyUser", err)
+	}
+	return &u, strings.Fields(scopes), keyID, nil
+}
+
+func touchAPIKey(ctx context.Context, keyID int64) error {
+	query := "UPDATE api_keys SET last_used_at = NOW() WHERE id = ?"
+	ctx, span := startDBSpan(ctx, "touchAPIKey", query)
+	defer span.End()
+
+	if _, err := db.ExecContext(ctx, query, keyID); err != nil {
+		return storeError(ctx, "touchAPIKey", err)
+	}
+	return nil
+}
+
+func listAPIKeys(ctx context.Context, userID int64) ([]APIKey, error) {
+	query := `
+		SELECT id, name, key_prefix, scopes, created_at,
+			COALESCE(expires_at, ''), COALESCE(last_used_at, ''), COALESCE(revoked_at, '')
+		FROM api_keys WHERE user_id = ? ORDER BY id ASC`
+	ctx, span := startDBSpan(ctx, "listAPIKeys", query)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, storeError(ctx, "listAPIKeys", err)
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var k APIKey
+		var scopes string
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &scopes, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt); err != nil {
+			return nil, storeError(ctx, "listAPIKeys", err)
+		}
+		k.Scopes = strings.Fields(scopes)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storeError(ctx, "listAPIKeys", err)
+	}
+	return keys, nil
+}
+
+// revokeAPIKey отзывает ключ пользователя (администратор - любой ключ);
+// sql.ErrNoRows - ключа нет, он чужой или уже отозван
+func revokeAPIKey(ctx context.Context, u *User, keyID int64) error {
+	query := "UPDATE api_keys SET revoked_at = NOW() WHERE id = ? AND revoked_at IS NULL AND (user_id = ? OR ?)"
+	ctx, span := startDBSpan(ctx, "revokeAPIKey", query)
+	defer span.End()
+
+	res, err := db.ExecContext(ctx, query, keyID, u.ID, u.IsAdmin())
+	if err != nil {
+		return storeError(ctx, "revokeAPIKey", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return storeError(ctx, "revokeAPIKey", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// revokeToken запоминает ID токена до истечения его срока
+func revokeToken(ctx context.Context, jti string, expiresAt int64) error {
+	query := "INSERT IGNORE INTO revoked_tokens (jti, expires_at) VALUES (?, FROM_UNIXTIME(?))"
+	ctx, span := startDBSpan(ctx, "revokeToken", query)
+	defer span.End()
+
+	if _, err := db.ExecContext(ctx, query, jti, expiresAt); err != nil {
+		return storeError(ctx, "revokeToken", err)
+	}
+	// Истекшие токены отклоняются и без записи в таблице
+	if _, err := db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at <= NOW()"); err != nil {
+		return storeError(ctx, "revokeToken", err)
+	}
+	return nil
+}
+
+func isTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	query := "SELECT COUNT(*) FROM revoked_tokens WHERE jti = ?"
+	ctx, span := startDBSpan(ctx, "isTokenRevoked", query)
+	defer span.End()
+
+	var n int
+	if err := db.QueryRowContext(ctx, query, jti).Scan(&n); err != nil {
+		return false, storeError(ctx, "isTokenRevoked", err)
+	}
+	return n > 0, nil
+}
+
+// Аутентификация по ключу и токену
+
+func userByAPIKey(ctx context.Context, key string) (*User, error) {
+	u, scopes, keyID, err := getAPIKeyUser(ctx, hashToken(key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Права ключа не могут превышать текущие права владельца
+	u.Scopes = grantedScopes(u.Role, scopes)
+	u.authMethod = authAPIKey
+
+	touchAPIKey(ctx, keyID)
+	return u, nil
+}
+
+func userByToken(ctx context.Context, token string) (*User, error) {
+	claims, err := parseToken(token, appConfig.TokenSecret, time.Now())
+	if err != nil {
+		return nil, errInvalidCredentials
+	}
+	revoked, err := isTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errInvalidCredentials
+	}
+
+	// Роль берется из БД, а не из токена: удаленный пользователь теряет доступ сразу,
+	// а пониженный - права, которых у его роли больше нет
+	u, err := getUserByID(ctx, claims.Subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.Scopes = grantedScopes(u.Role, strings.Fields(claims.Scope))
+	u.authMethod = authToken
+	return u, nil
+}
+
+// Обработчики
+
+// apiKeysHandler возвращает ключи текущего пользователя (без самих ключей)
+func apiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	keys, err := listAPIKeys(r.Context(), currentUser(r.Context()).ID)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка при получении ключей: %v", err),
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Data:    keys,
+	}, http.StatusOK)
+}
+
+// createAPIKeyHandler выпускает ключ API; ключ возвращается только в этом ответе
+func createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := currentUser(r.Context())
+	var req APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Название ключа обязательно (до 100 символов)",
+		}, http.StatusBadRequest)
+		return
+	}
+	if err := validateScopes(u, req.Scopes); err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+	var expiresIn time.Duration
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			jsonResponse(w, Response{
+				Success: false,
+				Message: "Неверный срок действия ключа, пример: 720h",
+			}, http.StatusBadRequest)
+			return
+		}
+		expiresIn = d
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Ошибка генерации ключа",
+		}, http.StatusInternalServerError)
+		return
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = u.Scopes
+	}
+
+	id, err := createAPIKey(r.Context(), u.ID, req.Name, key[:len(apiKeyPrefix)+6], hashToken(key), scopes, expiresIn)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка при сохранении ключа: %v", err),
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Data:    map[string]interface{}{"id": id, "key": key, "scopes": scopes},
+		Message: "Ключ создан. Сохраните его: повторно он не показывается",
+	}, http.StatusCreated)
+}
+
+func revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" && r.Method != "POST" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Неверный ID ключа",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	if err := revokeAPIKey(r.Context(), currentUser(r.Context()), id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка при отзыве ключа: %v", err),
+		}, status)
+		return
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Message: "Ключ отозван",
+	}, http.StatusOK)
+}
+
+// issueTokenHandler выпускает подписанный bearer-токен с ограниченным сроком действия
+func issueTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := currentUser(r.Context())
+	var req TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if err := validateScopes(u, req.Scopes); err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	ttl := appConfig.TokenTTL
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 || d > appConfig.TokenMaxTTL {
+			jsonResponse(w, Response{
+				Success: false,
+				Message: fmt.Sprintf("Неверный срок действия токена: от 1s до %s", appConfig.TokenMaxTTL),
+			}, http.StatusBadRequest)
+			return
+		}
+		ttl = d
+	}
+
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = u.Scopes
+	}
+	jti := make([]byte, 16)
+	rand.Read(jti)
+	now := time.Now()
+	claims := tokenClaims{
+		Subject:   u.ID,
+		Username:  u.Username,
+		Role:      u.Role,
+		Scope:     strings.Join(scopes, " "),
+		ID:        hex.EncodeToString(jti),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+	token, err := signToken(claims, appConfig.TokenSecret)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка выпуска токена: %v", err),
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"token":      token,
+			"token_type": "Bearer",
+			"expires_in": int64(ttl / time.Second),
+			"scopes":     scopes,
+		},
+	}, http.StatusCreated)
+}
+
+// revokeTokenHandler отзывает bearer-токен, переданный в теле запроса
+func revokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	u := currentUser(r.Context())
+	claims, err := parseToken(req.Token, appConfig.TokenSecret, time.Now())
+	if err != nil || (claims.Subject != u.ID && !u.IsAdmin()) {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Неверный или просроченный токен",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	if err := revokeToken(r.Context(), claims.ID, claims.ExpiresAt); err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка при отзыве токена: %v", err),
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Message: "Токен отозван",
+	}, http.StatusOK)
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Действия в журнале аудита
+const (
+	auditSave      = "save"
+	auditSort      = "sort"
+	auditDelete    = "delete"
+	auditReindex   = "reindex"
+	auditRestore   = "restore"
+	auditPurge     = "purge"
+	auditEdit      = "edit"
+	auditRevert    = "revert"
+	auditTransform = "transform"
+)
+
+var auditActions = []string{auditSave, auditSort, auditDelete, auditReindex, auditRestore, auditPurge, auditEdit, auditRevert, auditTransform}
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditEntry - изменение таблицы arrays; автор и ID запроса берутся из контекста
+type auditEntry struct {
+	Action  string
+	ArrayID int64       // 0 - изменение не относится к одному массиву (reindex)
+	Before  interface{} // состояние до изменения, сохраняется в JSON
+	After   interface{} // состояние после изменения
+}
+
+// arraySnapshot - содержимое массива в журнале аудита
+type arraySnapshot struct {
+	ID        int64  `json:"id"`
+	Array     []int  `json:"array,omitempty"`
+	ArrayData string `json:"array_data,omitempty"` // исходная строка, если это не список чисел
+	IsSorted  bool   `json:"is_sorted"`
+	OwnerID   int64  `json:"owner_id,omitempty"`
+	Version   int    `json:"version,omitempty"`
+}
+
+// newArraySnapshot разбирает строку таблицы arrays для журнала
+func newArraySnapshot(id int64, arrayData string, isSorted bool, ownerID sql.NullInt64) arraySnapshot {
+	snap := arraySnapshot{ID: id, IsSorted: isSorted, OwnerID: ownerID.Int64}
+	if numbers, err := parseArrayString(arrayData); err == nil {
+		snap.Array = numbers
+	} else {
+		snap.ArrayData = arrayData
+	}
+	return snap
+}
+
+// renumbering - смена ID массива при переиндексации
+type renumbering struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// recordAudit добавляет запись в журнал в той же транзакции, что и само изменение:
+// изменение без записи в журнале не фиксируется
+func recordAudit(ctx context.Context, tx *sql.Tx, e auditEntry) error {
+	var actorID sql.NullInt64
+	actor, authMethod := "system", ""
+	if u := currentUser(ctx); u != nil {
+		actorID = sql.NullInt64{Int64: u.ID, Valid: true}
+		actor, authMethod = u.Username, u.authMethod
+	}
+
+	var arrayID sql.NullInt64
+	if e.ArrayID != 0 {
+		arrayID = sql.NullInt64{Int64: e.ArrayID, Valid: true}
+	}
+
+	before, err := auditJSON(e.Before)
+	if err != nil {
+		return err
+	}
+	after, err := auditJSON(e.After)
+	if err != nil {
+		return err
+	}
+
+	_, err = execTx(ctx, tx, "recordAudit", `
+		INSERT INTO audit_log (actor_id, actor, auth_method, action, array_id, before_data, after_data, request_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		actorID, actor, authMethod, e.Action, arrayID, before, after, requestID(ctx))
+	if err != nil {
+		return fmt.Errorf("ошибка записи в журнал аудита: %v", err)
+	}
+	return nil
+}
+
+func auditJSON(v interface{}) (sql.NullString, error) {
+	if v == nil {
+		return sql.NullString{}, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
+
+// AuditRecord - запись журнала аудита в ответе API
+type AuditRecord struct {
+	ID         int64           `json:"id"`
+	CreatedAt  string          `json:"created_at"`
+	ActorID    *int64          `json:"actor_id"`
+	Actor      string          `json:"actor"`
+	AuthMethod string          `json:"auth_method,omitempty"`
+	Action     string          `json:"action"`
+	ArrayID    *int64          `json:"array_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+}
+
+// auditFilter - условия выборки из журнала; нулевые поля не фильтруют
+type auditFilter struct {
+	ActorID   int64
+	Actor     string
+	Action    string
+	ArrayID   int64
+	RequestID string
+	Since     time.Time
+	Until     time.Time
+	BeforeID  int64 // для постраничного вывода: только записи с меньшим ID
+	Limit     int
+}
+
+// listAuditLog возвращает записи журнала от новых к старым
+func listAuditLog(ctx context.Context, f auditFilter) ([]AuditRecord, error) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		conds = append(conds, cond)
+		args = append(args, arg)
+	}
+	if f.ActorID != 0 {
+		add("actor_id = ?", f.ActorID)
+	}
+	if f.Actor != "" {
+		add("actor = ?", f.Actor)
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.ArrayID != 0 {
+		add("array_id = ?", f.ArrayID)
+	}
+	if f.RequestID != "" {
+		add("request_id = ?", f.RequestID)
+	}
+	if !f.Since.IsZero() {
+		add("created_at >= ?", f.Since.Local().Format(time.DateTime))
+	}
+	if !f.Until.IsZero() {
+		add("created_at < ?", f.Until.Local().Format(time.DateTime))
+	}
+	if f.BeforeID != 0 {
+		add("id < ?", f.BeforeID)
+	}
+	where := "TRUE"
+	if len(conds) > 0 {
+		where = strings.Join(conds, " AND ")
+	}
+
+	query := `
+		SELECT id, created_at, actor_id, actor, auth_method, action, array_id,
+			COALESCE(before_data, ''), COALESCE(after_data, ''), request_id
+		FROM audit_log
+		WHERE ` + where + `
+		ORDER BY id DESC
+		LIMIT ?`
+	ctx, span := startDBSpan(ctx, "listAuditLog", query)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, query, append(args, f.Limit)...)
+	if err != nil {
+		return nil, storeError(ctx, "listAuditLog", err)
+	}
+	defer rows.Close()
+
+	records := []AuditRecord{}
+	for rows.Next() {
+		var rec AuditRecord
+		var actorID, arrayID sql.NullInt64
+		var before, after string
+		err := rows.Scan(&rec.ID, &rec.CreatedAt, &actorID, &rec.Actor, &rec.AuthMethod, &rec.Action,
+			&arrayID, &before, &after, &rec.RequestID)
+		if err != nil {
+			return nil, storeError(ctx, "listAuditLog", err)
+		}
+		if actorID.Valid {
+			rec.ActorID = &actorID.Int64
+		}
+		if arrayID.Valid {
+			rec.ArrayID = &arrayID.Int64
+		}
+		if before != "" {
+			rec.Before = json.RawMessage(before)
+		}
+		if after != "" {
+			rec.After = json.RawMessage(after)
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storeError(ctx, "listAuditLog", err)
+	}
+	return records, nil
+}
+
+// auditLogHandler - журнал аудита для администратора (только чтение).
+// Фильтры: actor_id, actor, action, array_id, request_id, since, until (RFC 3339 или 2006-01-02),
+// before_id (постраничный вывод), limit
+func auditLogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	f, err := parseAuditFilter(r)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	records, err := listAuditLog(r.Context(), f)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка чтения журнала аудита: %v", err),
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	// next_before_id - значение before_id для следующей страницы
+	data := map[string]interface{}{"entries": records}
+	if len(records) == f.Limit {
+		data["next_before_id"] = records[len(records)-1].ID
+	}
+	jsonResponse(w, Response{
+		Success: true,
+		Data:    data,
+	}, http.StatusOK)
+}
+
+func parseAuditFilter(r *http.Request) (auditFilter, error) {
+	q := r.URL.Query()
+	f := auditFilter{
+		Actor:     q.Get("actor"),
+		Action:    q.Get("action"),
+		RequestID: q.Get("request_id"),
+		Limit:     defaultAuditLimit,
+	}
+
+	if f.Action != "" && !slices.Contains(auditActions, f.Action) {
+		return f, fmt.Errorf("неизвестное действие %q, доступны: %s", f.Action, strings.Join(auditActions, ", "))
+	}
+
+	ints := []struct {
+		name string
+		dst  *int64
+	}{{"actor_id", &f.ActorID}, {"array_id", &f.ArrayID}, {"before_id", &f.BeforeID}}
+	for _, p := range ints {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n <= 0 {
+				return f, fmt.Errorf("неверное значение %s: %q", p.name, v)
+			}
+			*p.dst = n
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxAuditLimit {
+			return f, fmt.Errorf("limit должен быть от 1 до %d", maxAuditLimit)
+		}
+		f.Limit = n
+	}
+
+	var err error
+	if f.Since, err = parseAuditTime(q.Get("since")); err != nil {
+		return f, fmt.Errorf("неверное значение since: %v", err)
+	}
+	if f.Until, err = parseAuditTime(q.Get("until")); err != nil {
+		return f, fmt.Errorf("неверное значение until: %v", err)
+	}
+	return f, nil
+}
+
+// parseAuditTime принимает время в RFC 3339 или дату 2006-01-02 (начало дня по времени сервера)
+func parseAuditTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, v, time.Local)
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookieName  = "rps_session"
+	passwordIterations = 600000 // рекомендация OWASP для PBKDF2-HMAC-SHA256
+	minPasswordLength  = 8
+)
+
+const userKey contextKey = "user"
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,32}$`)
+
+// dummyPasswordHash сравнивается при входе под несуществующим именем,
+// чтобы время ответа не выдавало, зарегистрирован ли пользователь
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := hashPassword("dummy-password")
+	return hash
+})
+
+type AuthRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// hashPassword возвращает строку вида pbkdf2-sha256$<итерации>$<соль>$<ключ>
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// checkPassword сравнивает пароль с хешем за постоянное время
+func checkPassword(encoded, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// hashToken - в БД хранится только SHA-256 токена, утечка таблицы не дает войти
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// currentUser возвращает пользователя, выполнившего вход, или nil
+func currentUser(ctx context.Context) *User {
+	u, _ := ctx.Value(userKey).(*User)
+	return u
+}
+
+// Способы аутентификации запроса
+const (
+	authSession = "session" // cookie сессии браузера
+	authAPIKey  = "api_key" // ключ API в Authorization: Bearer rps_... или X-API-Key
+	authToken   = "token"   // подписанный bearer-токен (JWT)
+)
+
+// errInvalidCredentials - клиент передал ключ или токен, но они недействительны
+var errInvalidCredentials = errors.New("неверный, отозванный или просроченный ключ доступа")
+
+// authenticate определяет пользователя по ключу API, bearer-токену или cookie сессии.
+// Запрос без учетных данных пропускается дальше анонимным - доступ проверяют
+// requireAuth и requireScope; с недействительными учетными данными - отклоняется
+func authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, err := authenticateRequest(r)
+		if errors.Is(err, errInvalidCredentials) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			jsonResponse(w, Response{
+				Success: false,
+				Message: err.Error(),
+			}, http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			jsonResponse(w, Response{
+				Success: false,
+				Message: "Ошибка проверки учетных данных",
+			}, http.StatusInternalServerError)
+			return
+		}
+
+		if u != nil {
+			r = r.WithContext(context.WithValue(r.Context(), userKey, u))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticateRequest возвращает nil, nil для анонимного запроса
+func authenticateRequest(r *http.Request) (*User, error) {
+	ctx := r.Context()
+
+	credential := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); credential == "" && auth != "" {
+		scheme, value, ok := strings.Cut(auth, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || value == "" {
+			return nil, errInvalidCredentials
+		}
+		credential = strings.TrimSpace(value)
+	}
+
+	if credential != "" {
+		if strings.HasPrefix(credential, apiKeyPrefix) {
+			return userByAPIKey(ctx, credential)
+		}
+		return userByToken(ctx, credential)
+	}
+
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, nil
+	}
+	u, err := getSessionUser(ctx, hashToken(cookie.Value))
+	if errors.Is(err, sql.ErrNoRows) {
+		// Сессия истекла или удалена - продолжаем как анонимный пользователь
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.Scopes = roleScopes(u.Role)
+	u.CSRFToken = csrfToken(cookie.Value)
+	u.authMethod = authSession
+	return u, nil
+}
+
+// requireAuth пропускает только пользователей, выполнивших вход
+func requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if currentUser(r.Context()) == nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			jsonResponse(w, Response{
+				Success: false,
+				Message: "Требуется вход в систему",
+			}, http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireScope пропускает пользователей, у которых есть право scope
+func requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !currentUser(r.Context()).HasScope(scope) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope))
+			jsonResponse(w, Response{
+				Success: false,
+				Message: fmt.Sprintf("Недостаточно прав: требуется %s", scope),
+			}, http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// requireSession пропускает только запросы с cookie сессии. Ключи API и токены выпускаются
+// после входа по паролю: иначе короткий токен или ключ мог бы продлить себе доступ новым ключом,
+// который не отзывается вместе с ним
+func requireSession(next http.HandlerFunc) http.HandlerFunc {
+	return requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if currentUser(r.Context()).authMethod != authSession {
+			jsonResponse(w, Response{
+				Success: false,
+				Message: "Ключи и токены выпускаются только после входа по паролю",
+			}, http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+func validateCredentials(username, password string) error {
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("имя пользователя: 3-32 символа, латинские буквы, цифры, '_', '.', '-'")
+	}
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("пароль должен быть не короче %d символов", minPasswordLength)
+	}
+	return nil
+}
+
+// ensureAdmin создает администратора из настроек или обновляет его пароль и роль
+func ensureAdmin(ctx context.Context, username, password string) error {
+	if err := validateCredentials(username, password); err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	u, _, err := getUserByUsername(ctx, username)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		_, err = createUser(ctx, username, hash, roleAdmin)
+		return err
+	case err != nil:
+		return err
+	default:
+		return setUserPassword(ctx, u.ID, hash, roleAdmin)
+	}
+}
+
+// startSession создает сессию и выставляет cookie
+func startSession(w http.ResponseWriter, r *http.Request, u *User) error {
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	if err := createSession(r.Context(), hashToken(token), u.ID, int64(appConfig.SessionTTL/time.Second)); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(appConfig.SessionTTL),
+		HttpOnly: true, // недоступна из JavaScript
+		Secure:   appConfig.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+	u.CSRFToken = csrfToken(token)
+	return nil
+}
+
+func registerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+	if !appConfig.AllowRegistration {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Регистрация отключена",
+		}, http.StatusForbidden)
+		return
+	}
+
+	var req AuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if err := validateCredentials(req.Username, req.Password); err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Ошибка обработки пароля",
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	u, err := createUser(r.Context(), req.Username, hash, roleUser)
+	if errors.Is(err, errUserExists) {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка регистрации: %v", err),
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	u.Scopes = roleScopes(u.Role)
+	if err := startSession(w, r, u); err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка создания сессии: %v", err),
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Data:    u,
+		Message: "Пользователь зарегистрирован",
+	}, http.StatusCreated)
+}
+
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	u, passwordHash, err := getUserByUsername(r.Context(), req.Username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка входа: %v", err),
+		}, http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		passwordHash = dummyPasswordHash()
+	}
+	if !checkPassword(passwordHash, req.Password) || u == nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Неверное имя пользователя или пароль",
+		}, http.StatusUnauthorized)
+		return
+	}
+
+	// Заодно убираем просроченные сессии
+	deleteExpiredSessions(r.Context())
+
+	u.Scopes = roleScopes(u.Role)
+	if err := startSession(w, r, u); err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка создания сессии: %v", err),
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Data:    u,
+	}, http.StatusOK)
+}
+
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if cookie, err := r.Cookie(sessionCookieName); err == nil && cookie.Value != "" {
+		if err := deleteSession(r.Context(), hashToken(cookie.Value)); err != nil {
+			jsonResponse(w, Response{
+				Success: false,
+				Message: fmt.Sprintf("Ошибка выхода: %v", err),
+			}, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   appConfig.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	jsonResponse(w, Response{
+		Success: true,
+		Message: "Выход выполнен",
+	}, http.StatusOK)
+}
+
+// meHandler возвращает текущего пользователя
+func meHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Data:    currentUser(r.Context()),
+	}, http.StatusOK)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config - настройки сервера, читаются из переменных окружения
+type Config struct {
+	Addr        string // адрес HTTP-сервера
+	DSN         string // строка подключения к MySQL
+	FrontendDir string // каталог с файлами интерфейса вместо встроенных (для разработки)
+	LogFormat   string // формат логов: text или json
+	LogLevel    string // минимальный уровень логов: debug, info, warn, error
+
+	TraceExporter string // куда выгружать спаны: none, stdout, file, otlp
+	TraceFile     string // файл для экспортера file
+	OTLPEndpoint  string // адрес OTLP/HTTP коллектора для экспортера otlp
+
+	TLSCertFile      string   // сертификат в формате PEM; вместе с TLSKeyFile включает HTTPS
+	TLSKeyFile       string   // закрытый ключ в формате PEM
+	TLSSelfSigned    bool     // создать самоподписанный сертификат при первом запуске (для разработки)
+	TLSHosts         []string // имена и IP-адреса в самоподписанном сертификате
+	HTTPRedirectAddr string   // адрес HTTP-сервера, перенаправляющего на HTTPS; пустой - не запускать
+
+	SessionTTL        time.Duration // срок действия сессии после входа
+	SecureCookies     bool          // выставлять cookie сессии только по HTTPS
+	AllowRegistration bool          // разрешить самостоятельную регистрацию
+	AdminUsername     string        // администратор, создаваемый при старте
+	AdminPassword     string
+
+	TokenSecret []byte        // ключ подписи bearer-токенов (HMAC-SHA256)
+	TokenTTL    time.Duration // срок действия токена по умолчанию
+	TokenMaxTTL time.Duration // максимальный срок, который можно запросить
+
+	ContentSecurityPolicy string // значение заголовка Content-Security-Policy; пустое - не отправлять
+
+	CORSOrigins     []string      // источники, которым разрешены кросс-доменные запросы ("*" - любые)
+	CORSMethods     []string      // методы, разрешенные в кросс-доменных запросах
+	CORSHeaders     []string      // заголовки, разрешенные в кросс-доменных запросах
+	CORSCredentials bool          // разрешить кросс-доменные запросы с cookie
+	CORSMaxAge      time.Duration // сколько браузер может кэшировать ответ на предварительный запрос
+
+	TrashRetention time.Duration // сколько удаленный массив хранится в корзине; 0 - бессрочно
+	Duplicates     string        // что делать при сохранении повтора: allow, reject или merge
+
+	MaxBodyBytes   int64   // наибольший размер тела запроса
+	MaxArrayLength int     // наибольшее число элементов в сохраняемом массиве
+	RateLimit      float64 // запросов в секунду с одного адреса; 0 - без ограничения
+	RateBurst      int     // сколько запросов подряд можно сделать сверх RateLimit
+
+	ReadTimeout     time.Duration // максимальное время чтения запроса
+	WriteTimeout    time.Duration // максимальное время записи ответа
+	IdleTimeout     time.Duration // время жизни простаивающего keep-alive соединения
+	ShutdownTimeout time.Duration // сколько ждать завершения активных запросов при остановке
+}
+
+// defaultContentSecurityPolicy разрешает только собственные скрипты и стили, шрифты Google Fonts
+// и запрещает встраивание страницы во фреймы
+const defaultContentSecurityPolicy = "default-src 'self'; script-src 'self'; " +
+	"style-src 'self' https://fonts.googleapis.com; font-src 'self' https://fonts.gstatic.com; " +
+	"img-src 'self' data:; connect-src 'self'; object-src 'none'; base-uri 'self'; " +
+	"form-action 'self'; frame-ancestors 'none'"
+
+func loadConfig() (Config, error) {
+	cfg := Config{
+		Addr:        getEnv("RPS_ADDR", ":8080"),
+		DSN:         getEnv("RPS_DB_DSN", "sorting_user:123@tcp(127.0.0.1:3306)/sorting_app"),
+		FrontendDir: getEnv("RPS_FRONTEND_DIR", ""),
+		LogFormat:   getEnv("RPS_LOG_FORMAT", "text"),
+		LogLevel:    getEnv("RPS_LOG_LEVEL", "info"),
+
+		TraceExporter: getEnv("RPS_TRACE_EXPORTER", "none"),
+		TraceFile:     getEnv("RPS_TRACE_FILE", "traces.json"),
+		OTLPEndpoint:  getEnv("RPS_OTLP_ENDPOINT", "http://localhost:4318"),
+
+		ContentSecurityPolicy: getEnv("RPS_CONTENT_SECURITY_POLICY", defaultContentSecurityPolicy),
+	}
+
+	var err error
+	if cfg.ReadTimeout, err = getEnvDuration("RPS_READ_TIMEOUT", 10*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.WriteTimeout, err = getEnvDuration("RPS_WRITE_TIMEOUT", 30*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.IdleTimeout, err = getEnvDuration("RPS_IDLE_TIMEOUT", 60*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.ShutdownTimeout, err = getEnvDuration("RPS_SHUTDOWN_TIMEOUT", 15*time.Second); err != nil {
+		return cfg, err
+	}
+
+	cfg.TLSCertFile = getEnv("RPS_TLS_CERT", "")
+	cfg.TLSKeyFile = getEnv("RPS_TLS_KEY", "")
+	if cfg.TLSSelfSigned, err = getEnvBool("RPS_TLS_SELF_SIGNED", false); err != nil {
+		return cfg, err
+	}
+	if cfg.TLSSelfSigned && cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		cfg.TLSCertFile, cfg.TLSKeyFile = "tls/cert.pem", "tls/key.pem"
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return cfg, fmt.Errorf("RPS_TLS_CERT и RPS_TLS_KEY задаются вместе")
+	}
+	cfg.TLSHosts = getEnvList("RPS_TLS_HOSTS", "localhost, 127.0.0.1, ::1")
+	cfg.HTTPRedirectAddr = getEnv("RPS_HTTP_REDIRECT_ADDR", "")
+	if cfg.HTTPRedirectAddr != "" && !cfg.TLSEnabled() {
+		return cfg, fmt.Errorf("RPS_HTTP_REDIRECT_ADDR требует настроенного HTTPS (RPS_TLS_CERT/RPS_TLS_KEY или RPS_TLS_SELF_SIGNED)")
+	}
+
+	if cfg.SessionTTL, err = getEnvDuration("RPS_SESSION_TTL", 24*time.Hour); err != nil {
+		return cfg, err
+	}
+	// По HTTPS cookie сессии по умолчанию передаются только по защищенному соединению
+	if cfg.SecureCookies, err = getEnvBool("RPS_SECURE_COOKIES", cfg.TLSEnabled()); err != nil {
+		return cfg, err
+	}
+	if cfg.AllowRegistration, err = getEnvBool("RPS_ALLOW_REGISTRATION", true); err != nil {
+		return cfg, err
+	}
+	cfg.AdminUsername = getEnv("RPS_ADMIN_USERNAME", "")
+	cfg.AdminPassword = getEnv("RPS_ADMIN_PASSWORD", "")
+
+	cfg.TokenSecret = []byte(getEnv("RPS_TOKEN_SECRET", ""))
+	if cfg.TokenTTL, err = getEnvDuration("RPS_TOKEN_TTL", time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.TokenMaxTTL, err = getEnvDuration("RPS_TOKEN_MAX_TTL", 24*time.Hour); err != nil {
+		return cfg, err
+	}
+
+	cfg.CORSOrigins = getEnvList("RPS_CORS_ORIGINS", "*")
+	cfg.CORSMethods = getEnvList("RPS_CORS_METHODS", "GET, POST, PUT, PATCH, DELETE")
+	cfg.CORSHeaders = getEnvList("RPS_CORS_HEADERS", "Content-Type, Authorization, X-API-Key, X-Request-ID, X-CSRF-Token")
+	if cfg.CORSCredentials, err = getEnvBool("RPS_CORS_CREDENTIALS", false); err != nil {
+		return cfg, err
+	}
+	if cfg.CORSCredentials && slices.Contains(cfg.CORSOrigins, "*") {
+		return cfg, fmt.Errorf("RPS_CORS_CREDENTIALS=true нельзя сочетать с RPS_CORS_ORIGINS=*: перечислите источники явно")
+	}
+	if cfg.CORSMaxAge, err = getEnvDuration("RPS_CORS_MAX_AGE", 10*time.Minute); err != nil {
+		return cfg, err
+	}
+
+	if cfg.TrashRetention, err = getEnvDuration("RPS_TRASH_RETENTION", 30*24*time.Hour); err != nil {
+		return cfg, err
+	}
+	cfg.Duplicates = getEnv("RPS_DUPLICATES", duplicatesAllow)
+	if !slices.Contains(duplicatePolicies, cfg.Duplicates) {
+		return cfg, fmt.Errorf("RPS_DUPLICATES: неизвестное значение %q, доступны: %s", cfg.Duplicates, strings.Join(duplicatePolicies, ", "))
+	}
+	if cfg.MaxBodyBytes, err = getEnvInt64("RPS_MAX_BODY_BYTES", 1<<20); err != nil {
+		return cfg, err
+	}
+	maxArrayLength, err := getEnvInt64("RPS_MAX_ARRAY_LENGTH", 100000)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.MaxArrayLength = int(maxArrayLength)
+	if cfg.RateLimit, err = getEnvFloat("RPS_RATE_LIMIT", 20); err != nil {
+		return cfg, err
+	}
+	rateBurst, err := getEnvInt64("RPS_RATE_BURST", 40)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.RateBurst = int(rateBurst)
+	// При нулевой емкости корзина токенов отклоняла бы все запросы
+	if cfg.RateLimit > 0 && cfg.RateBurst < 1 {
+		return cfg, fmt.Errorf("RPS_RATE_BURST должен быть не меньше 1 при включенном RPS_RATE_LIMIT")
+	}
+
+	return cfg, nil
+}
+
+// TLSEnabled - сервер принимает соединения по HTTPS
+func (c Config) TLSEnabled() bool {
+	return c.TLSCertFile != ""
+}
+
+// getEnv возвращает значение переменной окружения или значение по умолчанию
+func getEnv(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// getEnvList разбирает список через запятую; пустые элементы пропускаются
+func getEnvList(key, def string) []string {
+	var list []string
+	for item := range strings.SplitSeq(getEnv(key, def), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvDuration разбирает длительность в формате time.ParseDuration ("10s", "1m30s")
+func getEnvDuration(key string, def time.Duration) (time.Duration, error) {
+	v := getEnv(key, "")
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("неверное значение %s=%q: ожидается длительность, например 10s", key, v)
+	}
+	return d, nil
+}
+
+// getEnvInt64 разбирает неотрицательное целое число
+func getEnvInt64(key string, def int64) (int64, error) {
+	v := getEnv(key, "")
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("неверное значение %s=%q: ожидается неотрицательное целое число", key, v)
+	}
+	return n, nil
+}
+
+// getEnvFloat разбирает неотрицательное число, например 2.5
+func getEnvFloat(key string, def float64) (float64, error) {
+	v := getEnv(key, "")
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, fmt.Errorf("неверное значение %s=%q: ожидается неотрицательное число", key, v)
+	}
+	return f, nil
+}
+
+// getEnvBool разбирает логическое значение (true/false, 1/0)
+func getEnvBool(key string, def bool) (bool, error) {
+	v := getEnv(key, "")
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("неверное значение %s=%q: ожидается true или false", key, v)
+	}
+	return b, nil
+}
@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestLoadConfigRateBurst(t *testing.T) {
+	t.Setenv("RPS_RATE_LIMIT", "20")
+	t.Setenv("RPS_RATE_BURST", "0")
+	if _, err := loadConfig(); err == nil {
+		t.Fatal("RPS_RATE_BURST=0 при включенном RPS_RATE_LIMIT должен отклоняться")
+	}
+
+	// Без ограничения частоты емкость корзины не используется
+	t.Setenv("RPS_RATE_LIMIT", "0")
+	if _, err := loadConfig(); err != nil {
+		t.Fatalf("RPS_RATE_LIMIT=0: %v", err)
+	}
+}
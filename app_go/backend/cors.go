@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsPolicy - правила CORS для запросов со сторонних страниц (RPS_CORS_*)
+type corsPolicy struct {
+	origins     []string // разрешенные источники: "*", "https://example.com", "https://*.example.com"
+	methods     []string // разрешенные методы, в верхнем регистре
+	headers     []string // разрешенные заголовки запроса, в нижнем регистре; "*" - любые
+	credentials bool     // разрешить cookie и заголовок Authorization
+	maxAge      time.Duration
+}
+
+// exposedHeaders - заголовки ответа, доступные скриптам на другой странице
+var exposedHeaders = []string{"X-Request-ID", "X-Trace-ID"}
+
+func newCORSPolicy(cfg Config) *corsPolicy {
+	p := &corsPolicy{
+		origins:     cfg.CORSOrigins,
+		credentials: cfg.CORSCredentials,
+		maxAge:      cfg.CORSMaxAge,
+	}
+	for _, m := range cfg.CORSMethods {
+		p.methods = append(p.methods, strings.ToUpper(m))
+	}
+	for _, h := range cfg.CORSHeaders {
+		p.headers = append(p.headers, strings.ToLower(h))
+	}
+	return p
+}
+
+// allowOrigin проверяет источник запроса по списку разрешенных
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	for _, allowed := range p.origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		// Шаблон поддомена: https://*.example.com
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok &&
+			len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+			!strings.ContainsAny(origin[len(prefix):len(origin)-len(suffix)], "/:") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *corsPolicy) allowHeaders(requested string) bool {
+	if slices.Contains(p.headers, "*") {
+		return true
+	}
+	for h := range strings.SplitSeq(requested, ",") {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h != "" && !slices.Contains(p.headers, h) {
+			return false
+		}
+	}
+	return true
+}
+
+// setOrigin выставляет заголовки, общие для предварительного и основного запроса
+func (p *corsPolicy) setOrigin(h http.Header, origin string) {
+	// "*" нельзя сочетать с учетными данными - тогда возвращаем сам источник
+	if slices.Contains(p.origins, "*") && !p.credentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// handle применяет политику ко всем маршрутам и сам отвечает на предварительные запросы (OPTIONS),
+// не передавая их обработчикам и проверке входа
+func (p *corsPolicy) handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r) // запрос не кросс-доменный
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+
+		requestMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Method == http.MethodOptions && requestMethod != "" {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+
+			requestHeaders := r.Header.Get("Access-Control-Request-Headers")
+			if !p.allowOrigin(origin) || !slices.Contains(p.methods, requestMethod) || !p.allowHeaders(requestHeaders) {
+				http.Error(w, "CORS-запрос не разрешен", http.StatusForbidden)
+				return
+			}
+
+			p.setOrigin(h, origin)
+			h.Set("Access-Control-Allow-Methods", strings.Join(p.methods, ", "))
+			if requestHeaders != "" {
+				if slices.Contains(p.headers, "*") {
+					h.Set("Access-Control-Allow-Headers", requestHeaders)
+				} else {
+					h.Set("Access-Control-Allow-Headers", strings.Join(p.headers, ", "))
+				}
+			}
+			if p.maxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(p.maxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if p.allowOrigin(origin) {
+			p.setOrigin(h, origin)
+			h.Set("Access-Control-Expose-Headers", strings.Join(exposedHeaders, ", "))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,35 +1,178 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"strconv"
-	"strings"
+	"log/slog"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
-func saveArrayToDB(numbers []int, isSorted bool) (int64, error) {
-	var sb strings.Builder
-	for i, num := range numbers {
-		if i > 0 {
-			sb.WriteString(",")
+// storeError пишет в лог ошибку операции с БД и возвращает ее без изменений.
+// Отсутствие записи, неприменимое изменение и повтор массива - ожидаемые ситуации, поэтому логируются с уровнем info
+func storeError(ctx context.Context, op string, err error) error {
+	level := slog.LevelError
+	var dup *duplicateError
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, errInvalidEdit) || errors.Is(err, errInvalidArrayData) || errors.As(err, &dup) {
+		level = slog.LevelInfo
+	}
+	if level == slog.LevelError {
+		spanError(ctx, err)
+	}
+	logger(ctx).Log(ctx, level, "ошибка БД", "op", op, "error", err)
+	return err
+}
+
+// isDuplicateKey - нарушение уникального ключа (ER_DUP_ENTRY)
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+// isDeadlock - InnoDB откатил транзакцию из-за взаимной блокировки (ER_LOCK_DEADLOCK); ее можно повторить
+func isDeadlock(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1213
+}
+
+// saveDeadlockRetries - сколько раз saveArrayToDB повторяет транзакцию после взаимной блокировки
+const saveDeadlockRetries = 3
+
+// ownerCondition ограничивает запрос к arrays (с псевдонимом a) массивами пользователя u.
+// Администратору доступны все массивы
+func ownerCondition(u *User) (string, []interface{}) {
+	if u.IsAdmin() {
+		return "TRUE", nil
+	}
+	return "a.owner_id = ?", []interface{}{u.ID}
+}
+
+// saveArrayToDB сохраняет массив как версию 1 и запись audit в журнал аудита; ID и новое
+// содержимое массива дописываются в запись автоматически. is_sorted вычисляется по содержимому.
+// dedup - не сохранять копию массива, который у владельца уже есть: тогда возвращается
+// его ID и *duplicateError. Блокировки findDuplicate при параллельном сохранении одного массива
+// могут привести к взаимной блокировке: тогда транзакция повторяется и находит сохраненный повтор
+func saveArrayToDB(ctx context.Context, ownerID int64, numbers []int, dedup bool, audit auditEntry) (id int64, err error) {
+	for attempt := 0; ; attempt++ {
+		id, err = saveArrayTx(ctx, ownerID, numbers, dedup, audit)
+		if !isDeadlock(err) || attempt == saveDeadlockRetries {
+			return id, err
 		}
-		sb.WriteString(strconv.Itoa(num))
+		logger(ctx).Warn("Повтор сохранения после взаимной блокировки", "attempt", attempt+1)
+	}
+}
+
+// saveArrayTx - одна попытка saveArrayToDB
+func saveArrayTx(ctx context.Context, ownerID int64, numbers []int, dedup bool, audit auditEntry) (int64, error) {
+	arrayStr := formatArray(numbers)
+	isSorted := isSortedAsc(numbers)
+
+	var id int64
+	err := inTx(ctx, "saveArrayToDB", func(ctx context.Context, tx *sql.Tx) error {
+		if dedup {
+			existing, err := findDuplicate(ctx, tx, ownerID, contentHash(numbers))
+			if err != nil {
+				return err
+			}
+			if existing != 0 {
+				id = existing
+				return &duplicateError{ID: existing}
+			}
+		}
+
+		args := append([]interface{}{arrayStr, isSorted, ownerID}, summaryColumns(numbers)...)
+		res, err := execTx(ctx, tx, "saveArrayToDB.insert", `
+			INSERT INTO arrays (array_data, is_sorted, owner_id, length, sum, min_value, max_value, mean, content_hash, elements_hash,
+				sort_order, inversions, nearly_sorted)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, args...)
+		if err != nil {
+			return err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		if err := writeArrayElements(ctx, tx, id, numbers); err != nil {
+			return err
+		}
+
+		if err := insertVersion(ctx, tx, id, 1, arrayStr, isSorted, versionChange{Action: versionCreate}); err != nil {
+			return err
+		}
+
+		audit.ArrayID = id
+		audit.After = arraySnapshot{ID: id, Array: numbers, IsSorted: isSorted, OwnerID: ownerID, Version: 1}
+		return recordAudit(ctx, tx, audit)
+	})
+	return id, err
+}
+
+// createArray сохраняет новый массив по тем же правилам, что и /arrays/save: с политикой повторов
+// policy и переиндексацией. Все операции, создающие массивы на сервере, сохраняют их через createArray.
+// Возвращает ID после переиндексации; existing - такой массив уже был (политика merge), возвращен его ID.
+// При политике reject повтор - ошибка *duplicateError
+func createArray(ctx context.Context, ownerID int64, numbers []int, policy string, audit auditEntry) (id int64, existing bool, err error) {
+	id, err = saveArrayToDB(ctx, ownerID, numbers, policy != duplicatesAllow, audit)
+	var dup *duplicateError
+	if errors.As(err, &dup) && policy == duplicatesMerge {
+		return dup.ID, true, nil
+	}
+	if err != nil {
+		return id, false, err
 	}
-	arrayStr := sb.String()
 
-	res, err := db.Exec("INSERT INTO arrays (array_data, is_sorted) VALUES (?, ?)", arrayStr, isSorted)
+	renumbered, err := reindexArrays(ctx)
 	if err != nil {
-		return 0, err
+		return id, false, fmt.Errorf("ошибка переиндексации: %w", err)
 	}
+	for _, c := range renumbered {
+		if c.From == id {
+			return c.To, false, nil
+		}
+	}
+	return id, false, nil
+}
+
+func getAllArrays(ctx context.Context, u *User) ([]map[string]interface{}, error) {
+	return queryArrays(ctx, u, "getAllArrays", arrayQuery{})
+}
 
-	return res.LastInsertId()
+// arrayQuery - отбор массивов для queryArrays; нулевое значение - все массивы
+type arrayQuery struct {
+	Filter string           // дополнительное условие SQL над arrays a
+	Args   []interface{}    // параметры Filter
+	Keep   func([]int) bool // проверка содержимого после чтения (nil - все)
+	Limit  int              // 0 - без ограничения
 }
 
-func getAllArrays() ([]map[string]interface{}, error) {
+// queryArrays возвращает массивы пользователя u (не из корзины), отобранные q, по возрастанию ID
+func queryArrays(ctx context.Context, u *User, op string, q arrayQuery) ([]map[string]interface{}, error) {
 	// Сортируем по текущим ID
 	// Query отправляет запрос к бд, rows - итератор для доступа к рез sql запроса
-	rows, err := db.Query("SELECT id, array_data, is_sorted FROM arrays ORDER BY id ASC")
+	cond, args := ownerCondition(u)
+	where := "a.deleted_at IS NULL AND " + cond
+	if q.Filter != "" {
+		where += " AND " + q.Filter
+		args = append(args, q.Args...)
+	}
+	query := `
+		SELECT a.id, a.array_data, COALESCE(u.username, ''), a.version, a.is_sorted, a.sort_order, a.inversions, a.nearly_sorted
+		FROM arrays a LEFT JOIN users u ON u.id = a.owner_id
+		WHERE ` + where + `
+		ORDER BY a.id ASC`
+	// Без проверки содержимого ограничение применяется в самом запросе
+	if q.Limit > 0 && q.Keep == nil {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+	ctx, span := startDBSpan(ctx, op, query)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, storeError(ctx, op, err)
 	}
 	defer rows.Close()
 
@@ -39,39 +182,149 @@ func getAllArrays() ([]map[string]interface{}, error) {
 	for rows.Next() {
 		var id int
 		var arrayData string
-		var isSorted bool
+		var owner string
+		var version int
+		var stored orderInfo
+		var sortOrder sql.NullString
+		var inversions sql.NullInt64
+		var nearlySorted sql.NullBool
 
-		err = rows.Scan(&id, &arrayData, &isSorted)
+		err = rows.Scan(&id, &arrayData, &owner, &version, &stored.IsSorted, &sortOrder, &inversions, &nearlySorted)
 		if err != nil {
-			return nil, err
+			return nil, storeError(ctx, op, err)
 		}
 
+		// Элементы отдаются только числами; строка, добавленная в обход API и не являющаяся
+		// списком чисел, возвращается пустой с пометкой invalid
+		numbers, err := parseArrayString(arrayData)
+		if err != nil {
+			logger(ctx).Warn("Некорректные данные массива в БД", "array_id", id)
+			numbers = []int{}
+		}
+		if q.Keep != nil && (err != nil || !q.Keep(numbers)) {
+			continue
+		}
+
+		// Упорядоченность сохраняется при записи; строки, которые еще не заполнил
+		// backfillArraySummaries (в них is_sorted мог задать клиент), проверяются по содержимому
+		var order orderInfo
+		switch {
+		case err != nil:
+			order = orderInfo{Order: orderNone}
+		case sortOrder.Valid:
+			stored.Order, stored.Inversions, stored.NearlySorted = sortOrder.String, inversions.Int64, nearlySorted.Bool
+			order = stored
+		default:
+			order = analyzeOrder(numbers)
+		}
 		arrays = append(arrays, map[string]interface{}{
-			"id":         id,
-			"array_data": arrayData,
-			"is_sorted":  isSorted,
+			"id":            id,
+			"array":         numbers,
+			"is_sorted":     order.IsSorted,
+			"order":         order.Order,
+			"inversions":    order.Inversions,
+			"nearly_sorted": order.NearlySorted,
+			"owner":         owner,
+			"invalid":       err != nil,
+			"version":       version,
 		})
+		if q.Limit > 0 && len(arrays) == q.Limit {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storeError(ctx, op, err)
 	}
 
 	return arrays, nil
 }
 
-func getArrayByID(id int) (string, error) {
+// errInvalidArrayData - в строке таблицы arrays не список целых чисел (например, запись добавлена в обход API)
+var errInvalidArrayData = errors.New("данные массива повреждены")
+
+// getArrayByID возвращает элементы массива. Содержимое поврежденной строки не попадает ни в ответ, ни в лог
+func getArrayByID(ctx context.Context, u *User, id int) ([]int, error) {
+	cond, args := ownerCondition(u)
+	query := "SELECT a.array_data FROM arrays a WHERE a.id = ? AND a.deleted_at IS NULL AND " + cond
+	ctx, span := startDBSpan(ctx, "getArrayByID", query)
+	defer span.End()
+
+	var arrayData string
+	err := db.QueryRowContext(ctx, query, append([]interface{}{id}, args...)...).Scan(&arrayData)
+	if err != nil {
+		return nil, storeError(ctx, "getArrayByID", err)
+	}
+
+	numbers, err := tracedParseArrayString(ctx, arrayData)
+	if err != nil {
+		logger(ctx).Warn("Некорректные данные массива в БД", "array_id", id)
+		return nil, errInvalidArrayData
+	}
+	return numbers, nil
+}
+
+// deleteArrayFromDB перемещает массив в корзину (deleted_at) и сохраняет его содержимое в журнале аудита
+func deleteArrayFromDB(ctx context.Context, u *User, id int) error {
+	return inTx(ctx, "deleteArrayFromDB", func(ctx context.Context, tx *sql.Tx) error {
+		snap, err := lockArray(ctx, tx, u, id, false)
+		if err != nil {
+			return err
+		}
+		if _, err := execTx(ctx, tx, "deleteArrayFromDB.update", "UPDATE arrays SET deleted_at = NOW() WHERE id = ?", id); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, auditEntry{Action: auditDelete, ArrayID: int64(id), Before: snap})
+	})
+}
+
+// lockArray читает массив пользователя u и блокирует строку до конца транзакции,
+// чтобы в журнал аудита попало именно изменяемое содержимое. deleted - искать в корзине
+func lockArray(ctx context.Context, tx *sql.Tx, u *User, id int, deleted bool) (arraySnapshot, error) {
+	cond, args := ownerCondition(u)
+	state := "a.deleted_at IS NULL"
+	if deleted {
+		state = "a.deleted_at IS NOT NULL"
+	}
+	query := "SELECT a.array_data, a.is_sorted, a.owner_id, a.version FROM arrays a WHERE a.id = ? AND " + state + " AND " + cond + " FOR UPDATE"
+	ctx, span := startDBSpan(ctx, "lockArray", query)
+	defer span.End()
+
 	var arrayData string
-	err := db.QueryRow("SELECT array_data FROM arrays WHERE id = ?", id).Scan(&arrayData)
-	return arrayData, err
+	var isSorted bool
+	var ownerID sql.NullInt64
+	var version int
+	err := tx.QueryRowContext(ctx, query, append([]interface{}{id}, args...)...).Scan(&arrayData, &isSorted, &ownerID, &version)
+	if err != nil {
+		return arraySnapshot{}, err
+	}
+	snap := newArraySnapshot(int64(id), arrayData, isSorted, ownerID)
+	snap.Version = version
+	return snap, nil
 }
 
-func reindexArrays() error {
+// reindexArrays перенумеровывает массивы по старшинству создания и возвращает изменившиеся ID
+func reindexArrays(ctx context.Context) (renumbered []renumbering, err error) {
+	start := time.Now()
+	ctx, span := startSpan(ctx, "reindexArrays")
+	defer span.End()
+
+	// Все ошибки переиндексации попадают в лог
+	defer func() {
+		reindexDuration.observe(time.Since(start).Seconds())
+		if err != nil {
+			storeError(ctx, "reindexArrays", err)
+		}
+	}()
+
 	// Проверяем соединение с БД
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("проверка соединения с БД не удалась: %v", err)
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("проверка соединения с БД не удалась: %v", err)
 	}
 
 	// Начинаем транзакцию
-	tx, err := db.Begin()
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("не удалось начать транзакцию: %v", err)
+		return nil, fmt.Errorf("не удалось начать транзакцию: %v", err)
 	}
 	defer func() {
 		if err != nil {
@@ -81,35 +334,108 @@ func reindexArrays() error {
 
 	// Временная таблица для переиндексации
 	// _ - игнорируем результат (кол-во строк)
-	_, err = tx.Exec(`
-		CREATE TEMPORARY TABLE IF NOT EXISTS temp_reindex AS 
-		SELECT id, ROW_NUMBER() OVER (ORDER BY created_at) as new_id 
+	_, err = execTx(ctx, tx, "reindexArrays.createTemp", `
+		CREATE TEMPORARY TABLE IF NOT EXISTS temp_reindex AS
+		SELECT id, ROW_NUMBER() OVER (ORDER BY created_at) as new_id
 		FROM arrays
 	`)
 	if err != nil {
-		return fmt.Errorf("ошибка создания временной таблицы: %v", err)
+		return nil, fmt.Errorf("ошибка создания временной таблицы: %v", err)
+	}
+
+	// Запоминаем, какие ID изменятся, для журнала аудита
+	renumbered, err = reindexChanges(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения новых ID: %v", err)
 	}
 
 	// Обновляем ID
-	_, err = tx.Exec(`
+	_, err = execTx(ctx, tx, "reindexArrays.update", `
 			UPDATE arrays a
 			JOIN temp_reindex t ON a.id = t.id
 			SET a.id = t.new_id
 	`)
 	if err != nil {
-		return fmt.Errorf("ошибка обновления ID: %v", err)
+		return nil, fmt.Errorf("ошибка обновления ID: %v", err)
 	}
 
 	// Удаляем временную таблицу
-	_, err = tx.Exec("DROP TEMPORARY TABLE temp_reindex")
+	_, err = execTx(ctx, tx, "reindexArrays.dropTemp", "DROP TEMPORARY TABLE temp_reindex")
 	if err != nil {
-		return fmt.Errorf("ошибка удаления временной таблицы: %v", err)
+		return nil, fmt.Errorf("ошибка удаления временной таблицы: %v", err)
+	}
+
+	// Переиндексация без изменений ID в журнал не попадает
+	if len(renumbered) > 0 {
+		err = recordAudit(ctx, tx, auditEntry{
+			Action: auditReindex,
+			After:  map[string]interface{}{"renumbered": renumbered},
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Фиксируем транзакцию
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("не удалось зафиксировать транзакцию: %v", err)
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("не удалось зафиксировать транзакцию: %v", err)
 	}
 
-	return nil
+	return renumbered, nil
+}
+
+// reindexChanges возвращает пары старый/новый ID из temp_reindex для массивов, чей ID меняется
+func reindexChanges(ctx context.Context, tx *sql.Tx) ([]renumbering, error) {
+	query := "SELECT id, new_id FROM temp_reindex WHERE id <> new_id ORDER BY id"
+	ctx, span := startDBSpan(ctx, "reindexArrays.changes", query)
+	defer span.End()
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []renumbering
+	for rows.Next() {
+		var c renumbering
+		if err := rows.Scan(&c.From, &c.To); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// inTx выполняет fn в транзакции: ошибка fn откатывает транзакцию и попадает в лог
+func inTx(ctx context.Context, op string, fn func(ctx context.Context, tx *sql.Tx) error) (err error) {
+	ctx, span := startSpan(ctx, op)
+	defer span.End()
+	defer func() {
+		if err != nil {
+			storeError(ctx, op, err)
+		}
+	}()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// execTx выполняет запрос в транзакции в отдельном спане
+func execTx(ctx context.Context, tx *sql.Tx, op, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startDBSpan(ctx, op, query)
+	defer span.End()
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		spanError(ctx, err)
+	}
+	return res, err
 }
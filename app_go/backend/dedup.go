@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Что делать при сохранении массива, который у владельца уже есть (RPS_DUPLICATES, параметр duplicates)
+const (
+	duplicatesAllow  = "allow"  // сохранить копию
+	duplicatesReject = "reject" // отказать с 409
+	duplicatesMerge  = "merge"  // не сохранять, вернуть ID существующего массива
+)
+
+var duplicatePolicies = []string{duplicatesAllow, duplicatesReject, duplicatesMerge}
+
+// codeDuplicateArray - такой массив у пользователя уже есть
+const codeDuplicateArray = "duplicate_array"
+
+// duplicateError - сохраняемый массив совпадает с существующим массивом ID
+type duplicateError struct {
+	ID int64
+}
+
+func (e *duplicateError) Error() string {
+	return fmt.Sprintf("такой массив уже сохранен с ID %d", e.ID)
+}
+
+// contentHash - SHA-256 канонической записи массива ("1,2,3"): одинаковые элементы в том же порядке
+// дают одинаковый хэш независимо от пробелов во входной строке
+func contentHash(numbers []int) string {
+	sum := sha256.Sum256([]byte(formatArray(numbers)))
+	return hex.EncodeToString(sum[:])
+}
+
+// elementsHash - хэш отсортированного массива: совпадает у массивов из одних и тех же элементов
+// в любом порядке (например, у массива и его отсортированной копии)
+func elementsHash(numbers []int) string {
+	sorted := slices.Clone(numbers)
+	slices.Sort(sorted)
+	return contentHash(sorted)
+}
+
+// findDuplicate ищет у владельца ownerID массив (не из корзины) с хэшем hash и блокирует найденный
+// диапазон индекса до конца транзакции, чтобы параллельное сохранение не создало копию
+func findDuplicate(ctx context.Context, tx *sql.Tx, ownerID int64, hash string) (int64, error) {
+	query := `
+		SELECT id FROM arrays
+		WHERE owner_id = ? AND content_hash = ? AND deleted_at IS NULL
+		ORDER BY id LIMIT 1
+		FOR UPDATE`
+	ctx, span := startDBSpan(ctx, "findDuplicate", query)
+	defer span.End()
+
+	var id int64
+	err := tx.QueryRowContext(ctx, query, ownerID, hash).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// duplicateGroup - массивы с одинаковым содержимым (или набором элементов)
+type duplicateGroup struct {
+	Hash  string `json:"hash"`
+	IDs   []int  `json:"ids"`
+	Count int    `json:"count"`
+	Array []int  `json:"array"` // содержимое первого массива группы
+}
+
+// getDuplicateGroups возвращает группы из двух и более массивов пользователя u с одинаковым
+// столбцом column (content_hash или elements_hash), самые большие группы первыми
+func getDuplicateGroups(ctx context.Context, u *User, column string) ([]duplicateGroup, error) {
+	cond, args := ownerCondition(u)
+	query := `
+		SELECT d.hash, d.id, d.array_data FROM (
+			SELECT a.` + column + ` AS hash, a.id, a.array_data, COUNT(*) OVER (PARTITION BY a.` + column + `) AS n
+			FROM arrays a
+			WHERE a.deleted_at IS NULL AND a.` + column + ` IS NOT NULL AND ` + cond + `
+		) d
+		WHERE d.n > 1
+		ORDER BY d.n DESC, d.hash, d.id`
+	ctx, span := startDBSpan(ctx, "getDuplicateGroups", query)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, storeError(ctx, "getDuplicateGroups", err)
+	}
+	defer rows.Close()
+
+	// Строки одной группы идут подряд
+	groups := []duplicateGroup{}
+	for rows.Next() {
+		var hash, arrayData string
+		var id int
+		if err := rows.Scan(&hash, &id, &arrayData); err != nil {
+			return nil, storeError(ctx, "getDuplicateGroups", err)
+		}
+		if n := len(groups); n > 0 && groups[n-1].Hash == hash {
+			groups[n-1].IDs = append(groups[n-1].IDs, id)
+			groups[n-1].Count++
+			continue
+		}
+		// Хэш вычисляется только для корректных массивов
+		numbers, _ := parseArrayString(arrayData)
+		groups = append(groups, duplicateGroup{Hash: hash, IDs: []int{id}, Count: 1, Array: numbers})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storeError(ctx, "getDuplicateGroups", err)
+	}
+	return groups, nil
+}
+
+// duplicatesPolicy - политика повторов запроса: параметр duplicates, по умолчанию RPS_DUPLICATES
+func duplicatesPolicy(r *http.Request) (string, error) {
+	policy := r.URL.Query().Get("duplicates")
+	if policy == "" {
+		policy = appConfig.Duplicates
+	}
+	if !slices.Contains(duplicatePolicies, policy) {
+		return "", fmt.Errorf("неизвестное значение duplicates %q, доступны: %s", policy, strings.Join(duplicatePolicies, ", "))
+	}
+	return policy, nil
+}
+
+// writeCreateError отвечает на ошибку createArray: повтор при политике reject - 409, остальное - 500
+func writeCreateError(w http.ResponseWriter, err error) {
+	var dup *duplicateError
+	if errors.As(err, &dup) {
+		jsonResponse(w, Response{
+			Success: false,
+			Code:    codeDuplicateArray,
+			Message: fmt.Sprintf("Массив не сохранен: %v", dup),
+			Data:    map[string]int64{"id": dup.ID},
+		}, http.StatusConflict)
+		return
+	}
+	jsonResponse(w, Response{
+		Success: false,
+		Message: fmt.Sprintf("Ошибка при сохранении: %v", err),
+	}, http.StatusInternalServerError)
+}
+
+// createdStatus - статус ответа операции, сохранившей массив id: 201, а если вместо нового массива
+// возвращен существующий (политика merge) - 200 с кодом duplicate_array и сообщением об этом.
+// Все операции, создающие массивы, отвечают на повтор через createdStatus
+func createdStatus(resp *Response, id int64, existing bool) int {
+	if existing {
+		resp.Code = codeDuplicateArray
+		resp.Message = fmt.Sprintf("Такой массив уже сохранен. ID: %d", id)
+		return http.StatusOK
+	}
+	return http.StatusCreated
+}
+
+// lookupByHashHandler - массивы с заданным содержимым: GET /arrays/by-hash?hash=... или ?array=1,2,3
+func lookupByHashHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	hash := strings.ToLower(r.URL.Query().Get("hash"))
+	if v := r.URL.Query().Get("array"); v != "" {
+		numbers, err := parseArrayString(v)
+		if err != nil {
+			jsonResponse(w, Response{
+				Success: false,
+				Message: fmt.Sprintf("Неверный формат массива: %v", err),
+			}, http.StatusBadRequest)
+			return
+		}
+		hash = contentHash(numbers)
+	}
+	if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha256.Size {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Укажите hash (SHA-256, 64 шестнадцатеричных символа) или array",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	arrays, err := queryArrays(r.Context(), currentUser(r.Context()), "lookupByHash",
+		arrayQuery{Filter: "a.content_hash = ?", Args: []interface{}{hash}})
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка поиска массивов: %v", err),
+		}, http.StatusInternalServerError)
+		return
+	}
+	if arrays == nil {
+		arrays = []map[string]interface{}{}
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Data:    map[string]interface{}{"hash": hash, "arrays": arrays},
+	}, http.StatusOK)
+}
+
+// duplicatesHandler - отчет о повторяющихся массивах: by=content (одинаковое содержимое, по умолчанию)
+// или by=elements (одинаковые элементы в любом порядке)
+func duplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	column := "content_hash"
+	switch by := r.URL.Query().Get("by"); by {
+	case "", "content":
+	case "elements":
+		column = "elements_hash"
+	default:
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Неизвестная группировка %q, доступны: content, elements", by),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	groups, err := getDuplicateGroups(r.Context(), currentUser(r.Context()), column)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка поиска повторов: %v", err),
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Data:    groups,
+	}, http.StatusOK)
+}
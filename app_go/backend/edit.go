@@ -0,0 +1,219 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// errInvalidEdit - изменение не применимо к текущему содержимому массива (индекс вне массива и т.п.)
+var errInvalidEdit = errors.New("неверное изменение массива")
+
+// arrayLengthError - после изменения массив превысил бы RPS_MAX_ARRAY_LENGTH
+// или предел длины алгоритма сортировки (reason поясняет, какой предел)
+type arrayLengthError struct {
+	length, limit int
+	reason        string // по умолчанию "(RPS_MAX_ARRAY_LENGTH)"
+}
+
+func (e *arrayLengthError) Error() string {
+	return fmt.Sprintf("массив из %d элементов превышает ограничение %d", e.length, e.limit)
+}
+
+func (e *arrayLengthError) Unwrap() error { return errInvalidEdit }
+
+// PatchRequest - список операций над сохраненным массивом, применяются по порядку
+type PatchRequest struct {
+	Operations []PatchOperation `json:"operations"`
+}
+
+// PatchOperation - одна операция изменения массива:
+//   - append: добавить values в конец
+//   - insert: вставить values перед элементом index (index = длина - в конец)
+//   - remove: удалить count элементов (по умолчанию 1) начиная с index
+//   - set: заменить элементы начиная с index на values
+type PatchOperation struct {
+	Op     string `json:"op"`
+	Index  *int   `json:"index,omitempty"`
+	Count  int    `json:"count,omitempty"`
+	Values string `json:"values,omitempty"` // в том же формате, что и array при сохранении: "1, 2, 3"
+}
+
+// applyPatch применяет операции к копии numbers. Новые элементы проходят ту же проверку, что и при сохранении
+func applyPatch(numbers []int, ops []PatchOperation) ([]int, error) {
+	result := slices.Clone(numbers)
+	for i, op := range ops {
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%w: операция %d (%s): %s", errInvalidEdit, i+1, op.Op, fmt.Sprintf(format, args...))
+		}
+
+		var values []int
+		if op.Op == "append" || op.Op == "insert" || op.Op == "set" {
+			var err error
+			if values, err = parseArrayString(op.Values); err != nil {
+				return nil, fail("%v", err)
+			}
+		}
+		index := 0
+		if op.Op != "append" {
+			if op.Index == nil {
+				return nil, fail("не указан index")
+			}
+			index = *op.Index
+		}
+
+		switch op.Op {
+		case "append":
+			result = append(result, values...)
+		case "insert":
+			if index < 0 || index > len(result) {
+				return nil, fail("index %d вне диапазона 0..%d", index, len(result))
+			}
+			result = slices.Insert(result, index, values...)
+		case "remove":
+			count := op.Count
+			if count == 0 {
+				count = 1
+			}
+			// Сравнение через len(result)-index: index+count переполняется при больших значениях
+			if count < 0 || index < 0 || index > len(result) || count > len(result)-index {
+				return nil, fail("нельзя удалить %d элементов с позиции %d из %d", count, index, len(result))
+			}
+			result = slices.Delete(result, index, index+count)
+		case "set":
+			if index < 0 || index > len(result) || len(values) > len(result)-index {
+				return nil, fail("нельзя заменить %d элементов с позиции %d из %d", len(values), index, len(result))
+			}
+			copy(result[index:], values)
+		default:
+			return nil, fmt.Errorf("%w: операция %d: неизвестная операция %q, доступны: append, insert, remove, set",
+				errInvalidEdit, i+1, op.Op)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%w: массив не может быть пустым", errInvalidEdit)
+	}
+	return result, nil
+}
+
+// updateArrayHandler изменяет сохраненный массив: PUT заменяет содержимое целиком (тело как при сохранении),
+// PATCH применяет операции PatchRequest. Каждое изменение - новая версия массива; признак is_sorted
+// вычисляется по новому содержимому, значение isSorted из запроса не используется
+func updateArrayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" && r.Method != "PATCH" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Неверный ID массива",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	var edit func(current arraySnapshot) ([]int, error)
+	if r.Method == "PUT" {
+		var req ArrayRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		if !checkArrayLength(w, strings.Count(req.Array, ",")+1, appConfig.MaxArrayLength, "(RPS_MAX_ARRAY_LENGTH)") {
+			return
+		}
+		numbers, err := tracedParseArrayString(r.Context(), req.Array)
+		if err != nil {
+			jsonResponse(w, Response{
+				Success: false,
+				Message: fmt.Sprintf("Неверный формат массива: %v", err),
+			}, http.StatusBadRequest)
+			return
+		}
+		// Замена не зависит от текущего содержимого, в том числе поврежденного
+		edit = func(arraySnapshot) ([]int, error) { return numbers, nil }
+	} else {
+		var req PatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		if len(req.Operations) == 0 {
+			jsonResponse(w, Response{
+				Success: false,
+				Message: "Не указаны операции (operations)",
+			}, http.StatusBadRequest)
+			return
+		}
+		edit = func(current arraySnapshot) ([]int, error) {
+			if current.Array == nil {
+				return nil, errInvalidArrayData
+			}
+			return applyPatch(current.Array, req.Operations)
+		}
+	}
+
+	limit := appConfig.MaxArrayLength
+	version, numbers, err := editArray(r.Context(), currentUser(r.Context()), id, versionChange{Action: versionEdit},
+		func(current arraySnapshot) ([]int, error) {
+			numbers, err := edit(current)
+			if err != nil {
+				return nil, err
+			}
+			if limit > 0 && len(numbers) > limit {
+				return nil, &arrayLengthError{length: len(numbers), limit: limit}
+			}
+			return numbers, nil
+		})
+	if err != nil {
+		writeEditError(w, err)
+		return
+	}
+
+	arrayOperationsTotal.inc("update")
+
+	jsonResponse(w, Response{
+		Success: true,
+		Message: fmt.Sprintf("Массив обновлен, версия %d", version),
+		Data: map[string]interface{}{
+			"id":      id,
+			"version": version,
+			"array":   numbers,
+			"order":   analyzeOrder(numbers),
+		},
+	}, http.StatusOK)
+}
+
+// writeEditError отвечает на ошибку editArray: 404/422 как при загрузке, 400 или 413 для
+// неприменимого изменения, иначе 500
+func writeEditError(w http.ResponseWriter, err error) {
+	var lengthErr *arrayLengthError
+	switch {
+	case errors.As(err, &lengthErr):
+		reason := lengthErr.reason
+		if reason == "" {
+			reason = "(RPS_MAX_ARRAY_LENGTH)"
+		}
+		checkArrayLength(w, lengthErr.length, lengthErr.limit, reason)
+	case errors.Is(err, errInvalidEdit):
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+	case errors.Is(err, errInvalidArrayData), errors.Is(err, sql.ErrNoRows):
+		writeLoadError(w, err)
+	default:
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка при обновлении массива: %v", err),
+		}, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestApplyPatchHugeIndexAndCount(t *testing.T) {
+	index1, indexMax := 1, math.MaxInt
+	cases := []struct {
+		name string
+		op   PatchOperation
+	}{
+		{"remove with huge count", PatchOperation{Op: "remove", Index: &index1, Count: math.MaxInt}},
+		{"remove with huge index", PatchOperation{Op: "remove", Index: &indexMax}},
+		{"set with huge index", PatchOperation{Op: "set", Index: &indexMax, Values: "1"}},
+		{"insert with huge index", PatchOperation{Op: "insert", Index: &indexMax, Values: "1"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := applyPatch([]int{3, 1, 2}, []PatchOperation{c.op})
+			if !errors.Is(err, errInvalidEdit) {
+				t.Fatalf("applyPatch: ожидалась errInvalidEdit, получено %v", err)
+			}
+			rec := httptest.NewRecorder()
+			writeEditError(rec, err)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("статус %d, ожидался %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	index := 1
+	got, err := applyPatch([]int{3, 1, 2}, []PatchOperation{
+		{Op: "set", Index: &index, Values: "7, 8"},
+		{Op: "append", Values: "9"},
+		{Op: "remove", Index: &index, Count: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{3, 9}; !slices.Equal(got, want) {
+		t.Fatalf("получено %v, ожидалось %v", got, want)
+	}
+}
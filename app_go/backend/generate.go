@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Распределения генератора случайных массивов
+const (
+	distUniform      = "uniform"       // равномерное на [min, max], как RANDOM_INPUT в cc_files
+	distNormal       = "normal"        // нормальное с центром диапазона и σ = (max-min)/6, обрезанное по диапазону
+	distSorted       = "sorted"        // равномерное, отсортированное по возрастанию
+	distReverse      = "reverse"       // равномерное, отсортированное по убыванию
+	distNearlySorted = "nearly_sorted" // отсортированное, в котором переставлено около 5% соседних пар
+	distFewUnique    = "few_unique"    // значения из нескольких (unique) случайных значений диапазона
+)
+
+var distributions = []string{distUniform, distNormal, distSorted, distReverse, distNearlySorted, distFewUnique}
+
+const (
+	defaultGenerateMin    = -500 // диапазон по умолчанию - как в testutils.GenerateRandomArray
+	defaultGenerateMax    = 499
+	defaultFewUniqueCount = 5
+
+	// maxGenerateSize ограничивает размер и при отключенном RPS_MAX_ARRAY_LENGTH
+	maxGenerateSize = 1000000
+
+	// maxGenerateValue ограничивает диапазон, чтобы число значений max-min+1 помещалось в uint64
+	maxGenerateValue = 1 << 62
+)
+
+// GenerateRequest - параметры генератора; нулевые значения заменяются значениями по умолчанию
+type GenerateRequest struct {
+	Size         int     `json:"size"`
+	Min          *int    `json:"min,omitempty"`
+	Max          *int    `json:"max,omitempty"`
+	Distribution string  `json:"distribution,omitempty"`
+	Unique       int     `json:"unique,omitempty"` // число различных значений для few_unique, не больше size и max-min+1
+	Seed         *uint64 `json:"seed,omitempty"`   // один seed с теми же параметрами дает тот же массив
+	Save         bool    `json:"save,omitempty"`   // сохранить массив, а не только вернуть
+}
+
+// validate проверяет параметры и подставляет значения по умолчанию
+func (req *GenerateRequest) validate() error {
+	if req.Size < 1 || req.Size > maxGenerateSize {
+		return fmt.Errorf("size должен быть от 1 до %d", maxGenerateSize)
+	}
+	if req.Min == nil {
+		v := defaultGenerateMin
+		req.Min = &v
+	}
+	if req.Max == nil {
+		v := defaultGenerateMax
+		req.Max = &v
+	}
+	if *req.Min >= *req.Max {
+		return fmt.Errorf("min должен быть меньше max")
+	}
+	if *req.Min < -maxGenerateValue || *req.Max > maxGenerateValue {
+		return fmt.Errorf("min и max должны быть в пределах ±2^62")
+	}
+	if req.Distribution == "" {
+		req.Distribution = distUniform
+	}
+	if !slices.Contains(distributions, req.Distribution) {
+		return fmt.Errorf("неизвестное распределение %q, доступны: %s", req.Distribution, strings.Join(distributions, ", "))
+	}
+	if req.Unique == 0 {
+		req.Unique = defaultFewUniqueCount
+	}
+	if req.Unique < 1 {
+		return fmt.Errorf("unique должен быть положительным")
+	}
+	// Различных значений не больше, чем элементов и чисел в диапазоне; иначе размер unique
+	// определял бы расход памяти независимо от size
+	req.Unique = min(req.Unique, req.Size)
+	if span := uint64(*req.Max) - uint64(*req.Min) + 1; span < uint64(req.Unique) {
+		req.Unique = int(span)
+	}
+	if req.Seed == nil {
+		seed := rand.Uint64()
+		req.Seed = &seed
+	}
+	return nil
+}
+
+// generateArray строит массив по проверенным параметрам req
+func generateArray(req GenerateRequest) []int {
+	rng := rand.New(rand.NewPCG(*req.Seed, 0))
+	lo, hi := *req.Min, *req.Max
+	// Разность считается в uint64: при широком диапазоне hi-lo не помещается в int
+	span := uint64(hi) - uint64(lo) + 1
+	uniform := func() int { return lo + int(rng.Uint64N(span)) }
+
+	numbers := make([]int, req.Size)
+	switch req.Distribution {
+	case distNormal:
+		mean, sd := (float64(lo)+float64(hi))/2, (float64(hi)-float64(lo))/6
+		for i := range numbers {
+			v := math.Round(rng.NormFloat64()*sd + mean)
+			numbers[i] = int(math.Min(math.Max(v, float64(lo)), float64(hi)))
+		}
+	case distFewUnique:
+		values := make([]int, req.Unique)
+		for i := range values {
+			values[i] = uniform()
+		}
+		for i := range numbers {
+			numbers[i] = values[rng.IntN(len(values))]
+		}
+	default:
+		for i := range numbers {
+			numbers[i] = uniform()
+		}
+	}
+
+	switch req.Distribution {
+	case distSorted, distNearlySorted:
+		slices.Sort(numbers)
+	case distReverse:
+		slices.Sort(numbers)
+		slices.Reverse(numbers)
+	}
+	if req.Distribution == distNearlySorted && len(numbers) > 1 {
+		for range max(1, len(numbers)/20) {
+			i := rng.IntN(len(numbers) - 1)
+			numbers[i], numbers[i+1] = numbers[i+1], numbers[i]
+		}
+	}
+	return numbers
+}
+
+// generateArrayHandler - POST /arrays/generate: случайный массив по параметрам GenerateRequest.
+// Без save массив только возвращается (например, для поля ввода в интерфейсе)
+func generateArrayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req GenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+	if !checkArrayLength(w, req.Size, appConfig.MaxArrayLength, "(RPS_MAX_ARRAY_LENGTH)") {
+		return
+	}
+	policy, err := duplicatesPolicy(r)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	numbers := generateArray(req)
+	data := map[string]interface{}{
+		"array":        numbers,
+		"seed":         *req.Seed,
+		"distribution": req.Distribution,
+	}
+
+	resp := Response{
+		Success: true,
+		Data:    data,
+	}
+	status := http.StatusOK
+	if req.Save {
+		id, existing, err := createArray(r.Context(), currentUser(r.Context()).ID, numbers, policy, auditEntry{
+			Action: auditSave,
+			Before: map[string]interface{}{"generator": req},
+		})
+		if err != nil {
+			writeCreateError(w, err)
+			return
+		}
+		data["id"] = id
+		status = createdStatus(&resp, id, existing)
+	}
+
+	arrayOperationsTotal.inc("generate")
+
+	jsonResponse(w, resp, status)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGenerateArray(t *testing.T) {
+	for _, dist := range distributions {
+		t.Run(dist, func(t *testing.T) {
+			seed, lo, hi := uint64(42), -5, 5
+			req := GenerateRequest{Size: 200, Min: &lo, Max: &hi, Distribution: dist, Seed: &seed}
+			if err := req.validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			a, b := generateArray(req), generateArray(req)
+			if !slices.Equal(a, b) {
+				t.Fatal("один seed дал разные массивы")
+			}
+			if len(a) != req.Size {
+				t.Fatalf("длина %d, ожидалась %d", len(a), req.Size)
+			}
+			for _, v := range a {
+				if v < lo || v > hi {
+					t.Fatalf("значение %d вне диапазона [%d, %d]", v, lo, hi)
+				}
+			}
+
+			switch dist {
+			case distSorted:
+				if !slices.IsSorted(a) {
+					t.Fatal("массив не отсортирован")
+				}
+			case distReverse:
+				slices.Reverse(a)
+				if !slices.IsSorted(a) {
+					t.Fatal("массив не отсортирован по убыванию")
+				}
+			case distFewUnique:
+				slices.Sort(a)
+				if n := len(slices.Compact(a)); n > defaultFewUniqueCount {
+					t.Fatalf("%d различных значений, ожидалось не больше %d", n, defaultFewUniqueCount)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateWideRange(t *testing.T) {
+	seed, lo, hi := uint64(1), -maxGenerateValue, maxGenerateValue
+	req := GenerateRequest{Size: 100, Min: &lo, Max: &hi, Seed: &seed}
+	if err := req.validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range generateArray(req) {
+		if v < lo || v > hi {
+			t.Fatalf("значение %d вне диапазона", v)
+		}
+	}
+}
+
+func TestGenerateValidate(t *testing.T) {
+	lo, hi := 0, 3
+	cases := []struct {
+		name    string
+		req     GenerateRequest
+		wantErr bool
+		unique  int
+	}{
+		{"пустой размер", GenerateRequest{}, true, 0},
+		{"слишком большой размер", GenerateRequest{Size: maxGenerateSize + 1}, true, 0},
+		{"min не меньше max", GenerateRequest{Size: 5, Min: &hi, Max: &lo}, true, 0},
+		{"неизвестное распределение", GenerateRequest{Size: 5, Distribution: "zipf"}, true, 0},
+		{"отрицательный unique", GenerateRequest{Size: 5, Unique: -1}, true, 0},
+		{"unique ограничен size", GenerateRequest{Size: 3, Distribution: distFewUnique, Unique: 2000000000}, false, 3},
+		{"unique ограничен диапазоном", GenerateRequest{Size: 100, Min: &lo, Max: &hi, Distribution: distFewUnique, Unique: 50}, false, 4},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.req.validate()
+			if (err != nil) != c.wantErr {
+				t.Fatalf("ошибка %v, ожидалась: %v", err, c.wantErr)
+			}
+			if !c.wantErr && c.req.Unique != c.unique {
+				t.Fatalf("unique = %d, ожидалось %d", c.req.Unique, c.unique)
+			}
+		})
+	}
+}
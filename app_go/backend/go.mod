@@ -1,7 +0,0 @@
-module RPS/app_go
-
-go 1.24.1
-
-require github.com/go-sql-driver/mysql v1.9.1
-
-require filippo.io/edwards25519 v1.1.0 // indirect
@@ -1,27 +1,32 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
-)
+	"time"
 
-func enableCORS(w *http.ResponseWriter) {
-	(*w).Header().Set("Access-Control-Allow-Origin", "*")                   // Разрешаем все домены
-	(*w).Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS") // Разрешение методов, options для предварительных CORS-запросов
-	(*w).Header().Set("Access-Control-Allow-Headers", "Content-Type")       // Разрешенные заголовки запросов
-}
+	"go.opentelemetry.io/otel/attribute"
+)
 
 type ArrayRequest struct {
 	Array    string `json:"array"`
-	IsSorted bool   `json:"isSorted"`
+	IsSorted bool   `json:"isSorted"` // необязателен: сервер сам определяет упорядоченность и отклоняет неверное true
 }
 
+// codeInvalidArrayData - сохраненный массив не является списком чисел
+const codeInvalidArrayData = "invalid_array_data"
+
 type Response struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"` //omitempty - пропуск поля при нулевом значении
+	Code    string      `json:"code,omitempty"`    // код ошибки для программ, например rate_limited
 	Data    interface{} `json:"data,omitempty"`
 }
 
@@ -29,14 +34,12 @@ type Response struct {
 // w http.ResponseWriter - формирование HTTP-ответа
 // r *http.Request - инофрмация об HTTP-запросе
 func arraysHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(&w) // настройка CORS
-
 	if r.Method != "GET" {
 		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
 		return
 	}
 
-	arrays, err := getAllArrays()
+	arrays, err := getAllArrays(r.Context(), currentUser(r.Context()))
 	if err != nil {
 		jsonResponse(w, Response{
 			Success: false,
@@ -59,16 +62,21 @@ func saveArrayHandler(w http.ResponseWriter, r *http.Request) {
 
 	var req ArrayRequest
 	// Декодирование json в ArrayRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		jsonResponse(w, Response{
-			Success: false,
-			Message: fmt.Sprintf("Ошибка декодирования запроса: %v", err),
-		}, http.StatusBadRequest)
+	_, span := startSpan(r.Context(), "decodeRequest")
+	err := json.NewDecoder(r.Body).Decode(&req)
+	span.End()
+	if err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	// Число элементов оцениваем по запятым до разбора строки
+	if !checkArrayLength(w, strings.Count(req.Array, ",")+1, appConfig.MaxArrayLength, "(RPS_MAX_ARRAY_LENGTH)") {
 		return
 	}
 
 	// Преобразуем строку в массив чисел с валидацией
-	numbers, err := parseArrayString(req.Array)
+	numbers, err := tracedParseArrayString(r.Context(), req.Array)
 	if err != nil {
 		jsonResponse(w, Response{
 			Success: false,
@@ -77,26 +85,35 @@ func saveArrayHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := saveArrayToDB(numbers, req.IsSorted)
-	if err != nil {
+	// Признак is_sorted вычисляется сервером; явно неверное утверждение клиента - ошибка в его данных
+	if req.IsSorted && !isSortedAsc(numbers) {
 		jsonResponse(w, Response{
 			Success: false,
-			Message: fmt.Sprintf("Ошибка при сохранении: %v", err),
-		}, http.StatusInternalServerError)
+			Code:    codeSortedFlagMismatch,
+			Message: "Массив передан как отсортированный, но не упорядочен по неубыванию",
+		}, http.StatusUnprocessableEntity)
 		return
 	}
 
-	// Выполняем переиндексацию
-	if err := reindexArrays(); err != nil {
+	// Повтор уже сохраненного массива: параметр duplicates, по умолчанию RPS_DUPLICATES
+	policy, err := duplicatesPolicy(r)
+	if err != nil {
 		jsonResponse(w, Response{
 			Success: false,
-			Message: fmt.Sprintf("Ошибка переиндексации: %v", err),
-		}, http.StatusInternalServerError)
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	// Сохраняем и переиндексируем
+	id, existing, err := createArray(r.Context(), currentUser(r.Context()).ID, numbers, policy, auditEntry{Action: auditSave})
+	if err != nil {
+		writeCreateError(w, err)
 		return
 	}
 
 	// Получаем обновленный список
-	arrays, err := getAllArrays()
+	arrays, err := getAllArrays(r.Context(), currentUser(r.Context()))
 	if err != nil {
 		jsonResponse(w, Response{
 			Success: false,
@@ -105,11 +122,16 @@ func saveArrayHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jsonResponse(w, Response{
+	if !existing {
+		arrayOperationsTotal.inc("save")
+	}
+
+	resp := Response{
 		Success: true,
 		Data:    arrays,
 		Message: fmt.Sprintf("Массив сохранен. База переиндексирована. Новый ID: %d", id),
-	}, http.StatusCreated)
+	}
+	jsonResponse(w, resp, createdStatus(&resp, id, existing))
 }
 
 func loadArrayHandler(w http.ResponseWriter, r *http.Request) {
@@ -129,18 +151,15 @@ func loadArrayHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	arrayData, err := getArrayByID(id)
+	numbers, err := getArrayByID(r.Context(), currentUser(r.Context()), id)
 	if err != nil {
-		jsonResponse(w, Response{
-			Success: false,
-			Message: fmt.Sprintf("Ошибка при загрузке массива: %v", err),
-		}, http.StatusNotFound)
+		writeLoadError(w, err)
 		return
 	}
 
 	jsonResponse(w, Response{
 		Success: true,
-		Data:    map[string]string{"array": arrayData},
+		Data:    map[string][]int{"array": numbers},
 	}, http.StatusOK)
 }
 
@@ -160,49 +179,49 @@ func sortArrayHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Загружаем массив из БД
-	arrayData, err := getArrayByID(id)
-	if err != nil {
+	// Алгоритм сортировки выбирается параметром algorithm (по умолчанию selection)
+	algorithm, alg, ok := lookupSortAlgorithm(r.URL.Query().Get("algorithm"))
+	if !ok {
 		jsonResponse(w, Response{
 			Success: false,
-			Message: fmt.Sprintf("Ошибка при загрузке массива: %v", err),
-		}, http.StatusNotFound)
-		return
-	}
-
-	// Парсим и сортируем массив
-	numbers, err := parseArrayString(arrayData)
-	if err != nil {
-		jsonResponse(w, Response{
-			Success: false,
-			Message: fmt.Sprintf("Неверный формат массива: %v", err),
+			Message: fmt.Sprintf("Неизвестный алгоритм сортировки %q, доступны: %s", algorithm, strings.Join(sortAlgorithmNames(), ", ")),
 		}, http.StatusBadRequest)
 		return
 	}
 
-	sortedNumbers := selectionSort(numbers)
+	// Массив сортируется в транзакции с заблокированной строкой: изменение, пришедшее
+	// во время сортировки, не будет перезаписано
+	reason := fmt.Sprintf("для алгоритма %s, используйте merge, quick или heap", algorithm)
+	change := versionChange{Action: versionSort, Algorithm: algorithm}
+	version, _, err := editArray(r.Context(), currentUser(r.Context()), id, change, func(current arraySnapshot) ([]int, error) {
+		if current.Array == nil {
+			return nil, errInvalidArrayData
+		}
+		numbers := current.Array
 
-	// Сохраняем отсортированный массив
-	_, err = saveArrayToDB(sortedNumbers, true)
-	if err != nil {
-		jsonResponse(w, Response{
-			Success: false,
-			Message: fmt.Sprintf("Ошибка при сохранении отсортированного массива: %v", err),
-		}, http.StatusInternalServerError)
-		return
-	}
+		// Квадратичные алгоритмы ограничены по длине, чтобы один запрос не занимал сервер надолго
+		if alg.maxLength > 0 && len(numbers) > alg.maxLength {
+			return nil, &arrayLengthError{length: len(numbers), limit: alg.maxLength, reason: reason}
+		}
 
-	// Выполняем переиндексацию
-	if err := reindexArrays(); err != nil {
-		jsonResponse(w, Response{
-			Success: false,
-			Message: fmt.Sprintf("Ошибка переиндексации: %v", err),
-		}, http.StatusInternalServerError)
+		// Сортируем массив
+		_, span := startSpan(r.Context(), "sort",
+			attribute.String("sort.algorithm", algorithm),
+			attribute.Int("array.length", len(numbers)))
+		defer span.End()
+		sortStart := time.Now()
+		sortedNumbers := alg.sort(slices.Clone(numbers))
+		sortDuration.observe(time.Since(sortStart).Seconds(), algorithm)
+		sortArrayLength.observe(float64(len(numbers)), algorithm)
+		return sortedNumbers, nil
+	})
+	if err != nil {
+		writeEditError(w, err)
 		return
 	}
 
 	// Получаем обновленный список
-	arrays, err := getAllArrays()
+	arrays, err := getAllArrays(r.Context(), currentUser(r.Context()))
 	if err != nil {
 		jsonResponse(w, Response{
 			Success: false,
@@ -211,20 +230,51 @@ func sortArrayHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	arrayOperationsTotal.inc("sort")
+
 	jsonResponse(w, Response{
 		Success: true,
 		Data:    arrays,
-		Message: "Массив успешно отсортирован и база переиндексирована",
+		Message: fmt.Sprintf("Массив успешно отсортирован, версия %d", version),
 	}, http.StatusOK)
 }
 
-
 // Вспомогательные функции
 
+// writeLoadError отвечает на ошибку getArrayByID: 422 для поврежденных данных, иначе 404
+func writeLoadError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errInvalidArrayData) {
+		jsonResponse(w, Response{
+			Success: false,
+			Code:    codeInvalidArrayData,
+			Message: fmt.Sprintf("Ошибка при загрузке массива: %v", err),
+		}, http.StatusUnprocessableEntity)
+		return
+	}
+	jsonResponse(w, Response{
+		Success: false,
+		Message: fmt.Sprintf("Ошибка при загрузке массива: %v", err),
+	}, http.StatusNotFound)
+}
+
 func jsonResponse(w http.ResponseWriter, data interface{}, statusCode int) {
 	w.Header().Set("Content-Type", "application/json") // Устанавливаем Content-Type
-	w.WriteHeader(statusCode) // Устанавливаем http статус
-	json.NewEncoder(w).Encode(data) // Петеровдим данные в json формат
+	w.WriteHeader(statusCode)                          // Устанавливаем http статус
+	json.NewEncoder(w).Encode(data)                    // Петеровдим данные в json формат
+}
+
+// tracedParseArrayString - parseArrayString в отдельном спане
+func tracedParseArrayString(ctx context.Context, input string) ([]int, error) {
+	ctx, span := startSpan(ctx, "parseArrayString", attribute.Int("input.bytes", len(input)))
+	defer span.End()
+
+	numbers, err := parseArrayString(input)
+	if err != nil {
+		spanError(ctx, err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("array.length", len(numbers)))
+	return numbers, nil
 }
 
 func parseArrayString(input string) ([]int, error) {
@@ -251,25 +301,7 @@ func parseArrayString(input string) ([]int, error) {
 	return numbers, nil
 }
 
-func selectionSort(arr []int) []int {
-	n := len(arr)
-	for i := 0; i < n-1; i++ {
-		minIndex := i
-		for j := i + 1; j < n; j++ {
-			if arr[j] < arr[minIndex] {
-				minIndex = j
-			}
-		}
-		if minIndex != i {
-			arr[i], arr[minIndex] = arr[minIndex], arr[i]
-		}
-	}
-	return arr
-}
-
 func deleteArrayHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(&w)
-
 	if r.Method != "DELETE" && r.Method != "POST" {
 		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
 		return
@@ -285,43 +317,37 @@ func deleteArrayHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = db.Exec("DELETE FROM arrays WHERE id = ?", id)
-	if err != nil {
+	if err := deleteArrayFromDB(r.Context(), currentUser(r.Context()), id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusNotFound
+		}
 		jsonResponse(w, Response{
 			Success: false,
 			Message: fmt.Sprintf("Ошибка при удалении массива: %v", err),
-		}, http.StatusInternalServerError)
+		}, status)
 		return
 	}
 
-	if err := reindexArrays(); err != nil {
-		jsonResponse(w, Response{
-			Success: false,
-			Message: fmt.Sprintf("Ошибка переиндексации: %v", err),
-		}, http.StatusInternalServerError)
-		return
-	}
+	// Массив перемещен в корзину и сохраняет свой ID, переиндексация не нужна;
+	// его можно вернуть через /arrays/restore
+	arrayOperationsTotal.inc("delete")
 
 	jsonResponse(w, Response{
 		Success: true,
-		Message: "Массив успешно удален",
+		Message: "Массив перемещен в корзину",
+		Data:    map[string]int{"id": id},
 	}, http.StatusOK)
 }
 
 func reindexArraysHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(&w)
-
 	if r.Method != "POST" {
 		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Переиндексация в одном запросе (по старшинству создания)
-	_, err := db.Exec(`
-			SET @new_id = 0;
-			UPDATE arrays SET id = (@new_id := @new_id + 1) ORDER BY id ASC;
-	`)
-	if err != nil {
+	// Переиндексация по старшинству создания
+	if _, err := reindexArrays(r.Context()); err != nil {
 		jsonResponse(w, Response{
 			Success: false,
 			Message: fmt.Sprintf("Ошибка переиндексации: %v", err),
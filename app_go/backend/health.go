@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// shuttingDown выставляется при получении сигнала остановки,
+// чтобы балансировщик перестал присылать новые запросы
+var shuttingDown atomic.Bool
+
+// healthzHandler - проверка живости: процесс запущен и отвечает
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Message: "ok",
+	}, http.StatusOK)
+}
+
+// readyzHandler - проверка готовности: БД доступна и схема в актуальной версии
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := checkReady(r.Context()); err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusServiceUnavailable)
+		return
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Message: "ready",
+	}, http.StatusOK)
+}
+
+func checkReady(ctx context.Context) error {
+	if shuttingDown.Load() {
+		return fmt.Errorf("сервер останавливается")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("БД недоступна: %v", err)
+	}
+
+	version, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if version < latestSchemaVersion() {
+		return fmt.Errorf("миграции не применены: версия схемы %d, требуется %d", version, latestSchemaVersion())
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// tokenClaims - содержимое bearer-токена (JWT, подпись HMAC-SHA256)
+type tokenClaims struct {
+	Subject   int64  `json:"sub"`   // ID пользователя
+	Username  string `json:"name"`  // имя пользователя
+	Role      string `json:"role"`  // роль на момент выпуска
+	Scope     string `json:"scope"` // права через пробел, например "arrays:read arrays:write"
+	ID        string `json:"jti"`   // ID токена для отзыва
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+var errInvalidToken = errors.New("неверный или просроченный токен")
+
+// jwtHeader - единственный поддерживаемый заголовок; токены с другим alg отклоняются
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// signToken кодирует и подписывает claims ключом secret
+func signToken(claims tokenClaims, secret []byte) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + tokenSignature(unsigned, secret), nil
+}
+
+// parseToken проверяет подпись и срок действия токена
+func parseToken(token string, secret []byte, now time.Time) (*tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return nil, errInvalidToken
+	}
+	want := tokenSignature(parts[0]+"."+parts[1], secret)
+	if !hmac.Equal([]byte(parts[2]), []byte(want)) {
+		return nil, errInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errInvalidToken
+	}
+	if claims.Subject == 0 || claims.ID == "" || now.Unix() >= claims.ExpiresAt {
+		return nil, errInvalidToken
+	}
+	return &claims, nil
+}
+
+func tokenSignature(unsigned string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Коды ошибок ограничений в поле code ответа
+const (
+	codeBodyTooLarge  = "body_too_large"
+	codeArrayTooLarge = "array_too_large"
+	codeRateLimited   = "rate_limited"
+)
+
+var rateLimitedTotal = newCounterVec("rps_rate_limited_total",
+	"Запросы, отклоненные ограничением частоты")
+
+// rateLimiter - ограничение частоты запросов по алгоритму token bucket, отдельная корзина на клиента
+type rateLimiter struct {
+	rate  float64 // пополнение, токенов в секунду
+	burst float64 // емкость корзины
+
+	mu        sync.Mutex
+	clients   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		clients: make(map[string]*tokenBucket),
+	}
+}
+
+// allow забирает токен из корзины клиента; если токенов нет, возвращает время до появления следующего
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	b, ok := l.clients[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.clients[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep раз в минуту удаляет корзины, которые успели заполниться: они не отличаются от новых
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.clients {
+		if now.Sub(b.last) >= full {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// unlimitedPaths - служебные маршруты и статика не расходуют лимит клиента
+var unlimitedPaths = []string{"/healthz", "/readyz", "/metrics", "/static/"}
+
+// limitRequests ограничивает размер тела запроса и частоту запросов с одного адреса
+func limitRequests(cfg Config, next http.Handler) http.Handler {
+	var limiter *rateLimiter
+	if cfg.RateLimit > 0 {
+		limiter = newRateLimiter(cfg.RateLimit, cfg.RateBurst)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && cfg.MaxBodyBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxBodyBytes)
+		}
+
+		if limiter != nil && !isUnlimitedPath(r.URL.Path) {
+			if ok, wait := limiter.allow(clientAddr(r), time.Now()); !ok {
+				rateLimitedTotal.inc()
+				seconds := int(math.Ceil(wait.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				jsonResponse(w, Response{
+					Success: false,
+					Code:    codeRateLimited,
+					Message: fmt.Sprintf("Слишком много запросов, повторите через %d с", seconds),
+				}, http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isUnlimitedPath(path string) bool {
+	for _, p := range unlimitedPaths {
+		if path == p || strings.HasSuffix(p, "/") && strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddr - IP-адрес клиента без порта
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// writeDecodeError отвечает на ошибку разбора JSON: 413, если тело больше RPS_MAX_BODY_BYTES, иначе 400
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		jsonResponse(w, Response{
+			Success: false,
+			Code:    codeBodyTooLarge,
+			Message: fmt.Sprintf("Тело запроса больше %d байт", tooLarge.Limit),
+		}, http.StatusRequestEntityTooLarge)
+		return
+	}
+	jsonResponse(w, Response{
+		Success: false,
+		Message: fmt.Sprintf("Ошибка декодирования запроса: %v", err),
+	}, http.StatusBadRequest)
+}
+
+// checkArrayLength отвечает 413, если в массиве больше limit элементов
+func checkArrayLength(w http.ResponseWriter, length, limit int, reason string) bool {
+	if limit <= 0 || length <= limit {
+		return true
+	}
+	jsonResponse(w, Response{
+		Success: false,
+		Code:    codeArrayTooLarge,
+		Message: fmt.Sprintf("Массив из %d элементов превышает ограничение %d %s", length, limit, reason),
+		Data:    map[string]int{"length": length, "limit": limit},
+	}, http.StatusRequestEntityTooLarge)
+	return false
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type contextKey string
+
+const requestInfoKey contextKey = "request_info"
+
+// requestInfo - сведения о запросе, общие для всех middleware. Хранится по указателю,
+// чтобы внешние middleware видели маршрут, выбранный ServeMux глубже по цепочке
+type requestInfo struct {
+	id    string
+	route string // шаблон маршрута ServeMux, например "/arrays/save"
+}
+
+// setupLogger настраивает slog по умолчанию: формат text или json и уровень логирования
+func setupLogger(w io.Writer, format, level string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("неверный уровень логирования %q: ожидается debug, info, warn или error", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("неверный формат логов %q: ожидается text или json", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// fatal логирует ошибку запуска и завершает процесс
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// logger возвращает логгер с ID текущего запроса и трассы
+func logger(ctx context.Context) *slog.Logger {
+	l := slog.Default()
+	if id := requestID(ctx); id != "" {
+		l = l.With("request_id", id)
+	}
+	if id := traceID(ctx); id != "" {
+		l = l.With("trace_id", id)
+	}
+	return l
+}
+
+// requestID возвращает ID запроса из контекста
+func requestID(ctx context.Context) string {
+	if info, ok := ctx.Value(requestInfoKey).(*requestInfo); ok {
+		return info.id
+	}
+	return ""
+}
+
+// requestRoute возвращает шаблон маршрута, обработавшего запрос
+func requestRoute(ctx context.Context) string {
+	if info, ok := ctx.Value(requestInfoKey).(*requestInfo); ok {
+		return info.route
+	}
+	return ""
+}
+
+// recordRoute запоминает маршрут, выбранный ServeMux; должен оборачивать сам mux
+func recordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if info, ok := r.Context().Value(requestInfoKey).(*requestInfo); ok {
+			info.route = r.Pattern
+		}
+	})
+}
+
+// newRequestID генерирует случайный ID запроса
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder запоминает код ответа и количество записанных байт
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap дает http.ResponseController доступ к исходному ResponseWriter
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// logRequests присваивает запросу ID (или берет его из X-Request-ID)
+// и после обработки пишет в лог метод, путь, статус, длительность и размер ответа
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 64 {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		info := &requestInfo{id: id}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey, info))
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case rec.status >= 500:
+			level = slog.LevelError
+		case rec.status >= 400:
+			level = slog.LevelWarn
+		}
+		// Спан запроса открывается внутри этого middleware, поэтому ID трассы берем из ответа
+		l := logger(r.Context())
+		if id := w.Header().Get("X-Trace-ID"); id != "" {
+			l = l.With("trace_id", id)
+		}
+		l.Log(r.Context(), level, "http запрос",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", info.route,
+			"status", rec.status,
+			"duration", time.Since(start),
+			"bytes", rec.bytes,
+			"remote", r.RemoteAddr,
+		)
+	})
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Запросы к содержимому одного массива
+const (
+	lookupSearch     = "search"      // индекс первого вхождения value (-1, если его нет)
+	lookupLowerBound = "lower_bound" // первый индекс с элементом >= value
+	lookupUpperBound = "upper_bound" // первый индекс с элементом > value
+	lookupCount      = "count"       // число элементов в диапазоне [min, max]
+	lookupKth        = "kth"         // k-й по возрастанию элемент (k с 1)
+)
+
+var lookupOperations = []string{lookupSearch, lookupLowerBound, lookupUpperBound, lookupCount, lookupKth}
+
+// codeArrayNotSorted - операция требует массива, отсортированного по возрастанию
+const codeArrayNotSorted = "array_not_sorted"
+
+// Как получен ответ
+const (
+	lookupMethodBinary      = "binary"      // двоичный поиск по отсортированному массиву, O(log n)
+	lookupMethodIndex       = "index"       // обращение по индексу отсортированного массива, O(1)
+	lookupMethodLinear      = "linear"      // проход по неотсортированному массиву, O(n)
+	lookupMethodQuickselect = "quickselect" // быстрый выбор по копии неотсортированного массива, в среднем O(n)
+)
+
+// lowerBound - первый индекс отсортированного numbers с элементом >= value (len, если таких нет)
+func lowerBound(numbers []int, value int) int {
+	i, _ := slices.BinarySearch(numbers, value)
+	return i
+}
+
+// upperBound - первый индекс отсортированного numbers с элементом > value (len, если таких нет)
+func upperBound(numbers []int, value int) int {
+	return sort.Search(len(numbers), func(i int) bool { return numbers[i] > value })
+}
+
+// quickselect переставляет numbers так, что на месте k (с 0) стоит k-й по возрастанию элемент,
+// левее - не большие, правее - не меньшие. Опорный элемент - медиана трех, разбиение на три части
+// (меньше, равно, больше), поэтому повторяющиеся значения не замедляют выбор
+func quickselect(numbers []int, k int) int {
+	lo, hi := 0, len(numbers)-1
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		pivot := max(min(numbers[lo], numbers[mid]), min(max(numbers[lo], numbers[mid]), numbers[hi]))
+
+		lt, i, gt := lo, lo, hi
+		for i <= gt {
+			switch {
+			case numbers[i] < pivot:
+				numbers[lt], numbers[i] = numbers[i], numbers[lt]
+				lt++
+				i++
+			case numbers[i] > pivot:
+				numbers[i], numbers[gt] = numbers[gt], numbers[i]
+				gt--
+			default:
+				i++
+			}
+		}
+
+		switch {
+		case k < lt:
+			hi = lt - 1
+		case k > gt:
+			lo = gt + 1
+		default:
+			return numbers[k]
+		}
+	}
+	return numbers[k]
+}
+
+// lookupResult - ответ на запрос к массиву; Index и Value отсутствуют, если элемента нет
+type lookupResult struct {
+	ID       int    `json:"id"`
+	Op       string `json:"op"`
+	Method   string `json:"method"`
+	IsSorted bool   `json:"is_sorted"`
+	Found    *bool  `json:"found,omitempty"`
+	Index    *int   `json:"index,omitempty"`
+	Value    *int   `json:"value,omitempty"`
+	Count    *int   `json:"count,omitempty"`
+	First    *int   `json:"first,omitempty"` // для count по отсортированному массиву: индексы диапазона
+	Last     *int   `json:"last,omitempty"`
+}
+
+// at заполняет Index и Value элементом numbers[i], если i внутри массива
+func (res *lookupResult) at(numbers []int, i int) {
+	if i >= 0 && i < len(numbers) {
+		res.Index, res.Value = &i, &numbers[i]
+	}
+}
+
+// lookupArray выполняет операцию op над numbers. search, lower_bound и upper_bound требуют
+// отсортированного массива (как после сортировки - is_sorted); count и kth работают с любым
+func lookupArray(numbers []int, op string, params map[string]int) (lookupResult, error) {
+	res := lookupResult{Op: op, Method: lookupMethodBinary, IsSorted: isSortedAsc(numbers)}
+	if !res.IsSorted && (op == lookupSearch || op == lookupLowerBound || op == lookupUpperBound) {
+		return res, fmt.Errorf("для %s массив должен быть отсортирован по возрастанию", op)
+	}
+
+	switch op {
+	case lookupSearch:
+		i, found := slices.BinarySearch(numbers, params["value"])
+		res.Found = &found
+		if found {
+			res.at(numbers, i)
+		} else {
+			notFound := -1
+			res.Index = &notFound
+		}
+	case lookupLowerBound:
+		i := lowerBound(numbers, params["value"])
+		res.at(numbers, i)
+		res.Index = &i
+	case lookupUpperBound:
+		i := upperBound(numbers, params["value"])
+		res.at(numbers, i)
+		res.Index = &i
+	case lookupCount:
+		lo, hi := params["min"], params["max"]
+		count := 0
+		if res.IsSorted {
+			first, end := lowerBound(numbers, lo), upperBound(numbers, hi)
+			if end > first {
+				last := end - 1
+				count = end - first
+				res.First, res.Last = &first, &last
+			}
+		} else {
+			res.Method = lookupMethodLinear
+			for _, v := range numbers {
+				if v >= lo && v <= hi {
+					count++
+				}
+			}
+		}
+		res.Count = &count
+	case lookupKth:
+		k := params["k"] - 1
+		if res.IsSorted {
+			res.Method = lookupMethodIndex
+			res.at(numbers, k)
+		} else {
+			// Индекс - первое вхождение найденного значения в исходном массиве
+			res.Method = lookupMethodQuickselect
+			value := quickselect(slices.Clone(numbers), k)
+			res.at(numbers, slices.Index(numbers, value))
+		}
+	}
+	return res, nil
+}
+
+// lookupHandler - запросы к массиву: GET /arrays/lookup?id=1&op=...
+//   - search, lower_bound, upper_bound с value - двоичный поиск по отсортированному массиву
+//   - count с min и max - число элементов в диапазоне
+//   - kth с k - k-й по возрастанию элемент
+func lookupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Неверный ID массива",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	op, params, err := parseLookupParams(r)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	numbers, err := getArrayByID(r.Context(), currentUser(r.Context()), id)
+	if err != nil {
+		writeLoadError(w, err)
+		return
+	}
+	if op == lookupKth && params["k"] > len(numbers) {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("k должен быть от 1 до %d (длина массива)", len(numbers)),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	_, span := startSpan(r.Context(), "lookupArray",
+		attribute.String("lookup.op", op),
+		attribute.Int("array.length", len(numbers)))
+	res, err := lookupArray(numbers, op, params)
+	span.End()
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Code:    codeArrayNotSorted,
+			Message: fmt.Sprintf("%v, сначала отсортируйте его", err),
+		}, http.StatusConflict)
+		return
+	}
+	res.ID = id
+
+	arrayOperationsTotal.inc("lookup")
+
+	jsonResponse(w, Response{
+		Success: true,
+		Data:    res,
+	}, http.StatusOK)
+}
+
+// parseLookupParams проверяет op и его числовые параметры
+func parseLookupParams(r *http.Request) (string, map[string]int, error) {
+	q := r.URL.Query()
+	op := q.Get("op")
+	var names []string
+	switch op {
+	case lookupSearch, lookupLowerBound, lookupUpperBound:
+		names = []string{"value"}
+	case lookupCount:
+		names = []string{"min", "max"}
+	case lookupKth:
+		names = []string{"k"}
+	default:
+		return "", nil, fmt.Errorf("неизвестная операция %q, доступны: %s", op, strings.Join(lookupOperations, ", "))
+	}
+
+	params := make(map[string]int, len(names))
+	for _, name := range names {
+		n, err := strconv.Atoi(q.Get(name))
+		if err != nil {
+			return "", nil, fmt.Errorf("для %s нужен целый параметр %s", op, name)
+		}
+		params[name] = n
+	}
+	if op == lookupCount && params["min"] > params["max"] {
+		return "", nil, fmt.Errorf("min больше max")
+	}
+	if op == lookupKth && params["k"] < 1 {
+		return "", nil, fmt.Errorf("k должен быть положительным")
+	}
+	return op, params, nil
+}
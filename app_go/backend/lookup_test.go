@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestBounds(t *testing.T) {
+	sorted := []int{1, 3, 3, 3, 7}
+	cases := []struct {
+		value, lower, upper int
+	}{
+		{0, 0, 0},
+		{1, 0, 1},
+		{2, 1, 1},
+		{3, 1, 4},
+		{7, 4, 5},
+		{8, 5, 5},
+	}
+	for _, c := range cases {
+		if got := lowerBound(sorted, c.value); got != c.lower {
+			t.Errorf("lowerBound(%d) = %d, ожидалось %d", c.value, got, c.lower)
+		}
+		if got := upperBound(sorted, c.value); got != c.upper {
+			t.Errorf("upperBound(%d) = %d, ожидалось %d", c.value, got, c.upper)
+		}
+	}
+}
+
+func TestQuickselect(t *testing.T) {
+	rng := rand.New(rand.NewPCG(5, 6))
+	inputs := [][]int{{1}, {2, 1}, {4, 4, 4, 4}, {5, -1, 3, -1, 0}}
+	for range 20 {
+		a := make([]int, 1+rng.IntN(200))
+		for i := range a {
+			a[i] = rng.IntN(15) - 7
+		}
+		inputs = append(inputs, a)
+	}
+
+	for _, in := range inputs {
+		sorted := slices.Clone(in)
+		slices.Sort(sorted)
+		for k := range in {
+			a := slices.Clone(in)
+			if got := quickselect(a, k); got != sorted[k] {
+				t.Fatalf("quickselect(%v, %d) = %d, ожидалось %d", in, k, got, sorted[k])
+			}
+			for i, v := range a {
+				if (i < k && v > a[k]) || (i > k && v < a[k]) {
+					t.Fatalf("quickselect(%v, %d): %v не разбит вокруг k", in, k, a)
+				}
+			}
+		}
+	}
+}
+
+func TestLookupArray(t *testing.T) {
+	sorted := []int{1, 3, 3, 7}
+	unsorted := []int{7, 3, 1, 3}
+	cases := []struct {
+		name    string
+		numbers []int
+		op      string
+		params  map[string]int
+		method  string
+		index   *int
+		count   *int
+		wantErr bool
+	}{
+		{"search найден", sorted, lookupSearch, map[string]int{"value": 3}, lookupMethodBinary, ptrInt(1), nil, false},
+		{"search не найден", sorted, lookupSearch, map[string]int{"value": 4}, lookupMethodBinary, ptrInt(-1), nil, false},
+		{"lower_bound за концом", sorted, lookupLowerBound, map[string]int{"value": 9}, lookupMethodBinary, ptrInt(4), nil, false},
+		{"upper_bound", sorted, lookupUpperBound, map[string]int{"value": 3}, lookupMethodBinary, ptrInt(3), nil, false},
+		{"count отсортированного", sorted, lookupCount, map[string]int{"min": 2, "max": 7}, lookupMethodBinary, nil, ptrInt(3), false},
+		{"count неотсортированного", unsorted, lookupCount, map[string]int{"min": 2, "max": 7}, lookupMethodLinear, nil, ptrInt(3), false},
+		{"kth отсортированного", sorted, lookupKth, map[string]int{"k": 4}, lookupMethodIndex, ptrInt(3), nil, false},
+		{"kth неотсортированного", unsorted, lookupKth, map[string]int{"k": 2}, lookupMethodQuickselect, ptrInt(1), nil, false},
+		{"search неотсортированного", unsorted, lookupSearch, map[string]int{"value": 3}, "", nil, nil, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := lookupArray(c.numbers, c.op, c.params)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("ошибка %v, ожидалась: %v", err, c.wantErr)
+			}
+			if c.wantErr {
+				return
+			}
+			if res.Method != c.method {
+				t.Errorf("method = %s, ожидалось %s", res.Method, c.method)
+			}
+			if c.index != nil && (res.Index == nil || *res.Index != *c.index) {
+				t.Errorf("index = %v, ожидалось %d", res.Index, *c.index)
+			}
+			if c.count != nil && (res.Count == nil || *res.Count != *c.count) {
+				t.Errorf("count = %v, ожидалось %d", res.Count, *c.count)
+			}
+		})
+	}
+}
+
+func ptrInt(v int) *int { return &v }
@@ -1,64 +1,234 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
-	"fmt"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"syscall"
 
 	_ "github.com/go-sql-driver/mysql" // Драйвер MySQL для работы с базой данных
 )
 
 var db *sql.DB // Глобальная переменная для хранения соединения с базой данных
 
+var appConfig Config // Настройки, прочитанные при запуске
+
 func main() {
+	cfg, err := loadConfig()
+	if err != nil {
+		fatal("Ошибка конфигурации", err)
+	}
+	appConfig = cfg
+	if err := setupLogger(os.Stderr, cfg.LogFormat, cfg.LogLevel); err != nil {
+		fatal("Ошибка конфигурации", err)
+	}
+
+	// Без заданного ключа токены подписываются случайным ключом и перестают действовать после перезапуска
+	if len(cfg.TokenSecret) == 0 {
+		appConfig.TokenSecret = make([]byte, 32)
+		rand.Read(appConfig.TokenSecret)
+		slog.Warn("RPS_TOKEN_SECRET не задан: bearer-токены будут недействительны после перезапуска")
+	}
+
+	// Трассировка запросов (OpenTelemetry)
+	shutdownTracing, err := setupTracing(context.Background(), cfg)
+	if err != nil {
+		fatal("Ошибка настройки трассировки", err)
+	}
+
 	// Инициализация БД
-	var err error
 	// ИНнициализация соединения с DB (тип данных, connection string)
-	db, err = sql.Open("mysql", "sorting_user:123@tcp(127.0.0.1:3306)/sorting_app")
+	db, err = sql.Open("mysql", cfg.DSN)
 	if err != nil {
-		log.Fatal(err)
+		fatal("Ошибка открытия БД", err)
 	}
 	defer db.Close() // Закрытие соединения с БД при завершении функции main
 
 	// Проверка подключения к базе данных
 	err = db.Ping()
 	if err != nil {
-		log.Fatal("Ошибка подключения к БД:", err) // Логирование ошибки подключения
+		fatal("Ошибка подключения к БД", err) // Логирование ошибки подключения
+	}
+
+	// Приводим схему БД к актуальной версии
+	if err := applyMigrations(db); err != nil {
+		fatal("Ошибка применения миграций", err)
+	}
+
+	// Сводка по элементам для массивов, сохраненных до ее появления
+	if n, err := backfillArraySummaries(context.Background()); err != nil {
+		fatal("Ошибка заполнения сводки массивов", err)
+	} else if n > 0 {
+		slog.Info("Заполнена сводка массивов", "arrays", n)
+	}
+	if n, err := backfillArrayElements(context.Background()); err != nil {
+		fatal("Ошибка заполнения индекса элементов", err)
+	} else if n > 0 {
+		slog.Info("Заполнен индекс элементов", "arrays", n)
 	}
 
-	// Получаем абсолютный путь к директории frontend
-	frontendPath := filepath.Join(getProjectRoot(), "frontend")
+	// Администратор из настроек создается (или получает новый пароль) при каждом запуске
+	if cfg.AdminUsername != "" {
+		if err := ensureAdmin(context.Background(), cfg.AdminUsername, cfg.AdminPassword); err != nil {
+			fatal("Ошибка создания администратора", err)
+		}
+	}
+
+	// Файлы интерфейса встроены в бинарник; RPS_FRONTEND_DIR позволяет раздавать их с диска
+	assets, err := newStaticAssets(cfg.FrontendDir)
+	if err != nil {
+		fatal("Ошибка загрузки файлов интерфейса", err)
+	}
 
 	// Определение маршрутов
-	http.HandleFunc("/arrays", arraysHandler)
-	http.HandleFunc("/arrays/save", saveArrayHandler)
-	http.HandleFunc("/arrays/load", loadArrayHandler)
-	http.HandleFunc("/arrays/sort", sortArrayHandler)
-	http.HandleFunc("/arrays/delete", deleteArrayHandler)
-	http.HandleFunc("/arrays/reindex", reindexArraysHandler)
-
-	// fs - файловый сервер
-	// Настройка статического сервера для обслуживания файлов из директории frontend
-	fs := http.FileServer(http.Dir(frontendPath))
-	http.Handle("/static/", http.StripPrefix("/static/", fs)) // Удаление префикса /static/ из URL
+	mux := http.NewServeMux()
+	// Массивы доступны только после входа (cookie, ключ API или bearer-токен) и при наличии права;
+	// каждый пользователь видит свои массивы, администратор - все
+	mux.HandleFunc("/arrays", requireScope(scopeArraysRead, arraysHandler))
+	mux.HandleFunc("/arrays/save", requireScope(scopeArraysWrite, saveArrayHandler))
+	mux.HandleFunc("/arrays/load", requireScope(scopeArraysRead, loadArrayHandler))
+	mux.HandleFunc("/arrays/sort", requireScope(scopeArraysWrite, sortArrayHandler))
+	mux.HandleFunc("/arrays/update", requireScope(scopeArraysWrite, updateArrayHandler)) // PUT - замена, PATCH - операции
+	mux.HandleFunc("/arrays/transform", requireScope(scopeArraysWrite, transformArrayHandler))
+	mux.HandleFunc("/arrays/combine", requireScope(scopeArraysWrite, combineArraysHandler))
+	mux.HandleFunc("/arrays/generate", requireScope(scopeArraysWrite, generateArrayHandler))
+	mux.HandleFunc("/arrays/topk", requireScope(scopeArraysWrite, topKHandler))
+	mux.HandleFunc("/arrays/partial-sort", requireScope(scopeArraysWrite, partialSortHandler))
+	mux.HandleFunc("/arrays/by-hash", requireScope(scopeArraysRead, lookupByHashHandler))
+	mux.HandleFunc("/arrays/duplicates", requireScope(scopeArraysRead, duplicatesHandler))
+	mux.HandleFunc("/arrays/search", requireScope(scopeArraysRead, searchArraysHandler))
+	mux.HandleFunc("/arrays/stats", requireScope(scopeArraysRead, statsHandler))
+	mux.HandleFunc("/arrays/lookup", requireScope(scopeArraysRead, lookupHandler))
+	mux.HandleFunc("/arrays/history", requireScope(scopeArraysRead, historyHandler))
+	mux.HandleFunc("/arrays/diff", requireScope(scopeArraysRead, diffHandler))
+	mux.HandleFunc("/arrays/revert", requireScope(scopeArraysWrite, revertHandler))
+	mux.HandleFunc("/arrays/delete", requireScope(scopeArraysWrite, deleteArrayHandler)) // перемещает в корзину
+	mux.HandleFunc("/arrays/trash", requireScope(scopeArraysRead, trashHandler))
+	mux.HandleFunc("/arrays/restore", requireScope(scopeArraysWrite, restoreArrayHandler))
+	mux.HandleFunc("/arrays/purge", requireScope(scopeArraysWrite, purgeArrayHandler))
+	mux.HandleFunc("/arrays/reindex", requireScope(scopeAdminReindex, reindexArraysHandler)) // перенумеровывает массивы всех пользователей
 
-	// Главная страница приложения (index.html)
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(frontendPath, "index.html")) // Отправка файла index.html клиенту
-	})
+	// Журнал изменений массивов, только для администратора
+	mux.HandleFunc("/admin/audit", requireScope(scopeAdminAudit, auditLogHandler))
 
-	fmt.Println("Сервер запущен на http://localhost:8080") // Сообщение о запуске сервера
-	log.Fatal(http.ListenAndServe(":8080", nil))           // Запуск HTTP-сервера на порту 8080 и логирование ошибок
-}
+	// Учетные записи
+	mux.HandleFunc("/auth/register", registerHandler)
+	mux.HandleFunc("/auth/login", loginHandler)
+	mux.HandleFunc("/auth/logout", logoutHandler)
+	mux.HandleFunc("/auth/me", requireAuth(meHandler))
+
+	// Ключи API и bearer-токены для скриптов
+	mux.HandleFunc("/auth/keys", requireAuth(apiKeysHandler))
+	mux.HandleFunc("/auth/keys/create", requireSession(createAPIKeyHandler))
+	mux.HandleFunc("/auth/keys/revoke", requireAuth(revokeAPIKeyHandler))
+	mux.HandleFunc("/auth/token", requireSession(issueTokenHandler))
+	mux.HandleFunc("/auth/token/revoke", requireAuth(revokeTokenHandler))
 
-// Функция для получения корневой директории проекта
-func getProjectRoot() string {
-	dir, err := os.Getwd() // Получение текущей рабочей директории
+	// Проверки живости и готовности
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler)
+
+	// Метрики в формате Prometheus
+	mux.HandleFunc("/metrics", metricsHandler)
+
+	// Статические файлы интерфейса (стили, скрипты, изображения)
+	mux.HandleFunc("/static/", assets.serveStatic)
+
+	// Главная страница приложения (index.html)
+	mux.HandleFunc("/", assets.serveIndex)
+
+	// HTTPS с сертификатом из файлов или самоподписанным
+	tlsConfig, err := setupTLS(cfg)
 	if err != nil {
-		log.Fatal(err) // Логирование ошибки при получении директории
+		fatal("Ошибка настройки TLS", err)
+	}
+
+	// HTTP/2 включен явно; без TLS браузеры его не используют, поэтому остается HTTP/1.1
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+
+	server := &http.Server{
+		Addr:         cfg.Addr,
+		TLSConfig:    tlsConfig,
+		Protocols:    protocols,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		// ID запроса, спан, лог, метрики, заголовки безопасности, CORS, ограничения, пользователь и
+		// проверка CSRF; CORS стоит до проверки входа, чтобы запросы OPTIONS не требовали учетных данных
+		Handler: logRequests(traceRequests(measureRequests(securityHeaders(cfg.ContentSecurityPolicy,
+			newCORSPolicy(cfg).handle(limitRequests(cfg, authenticate(protectCSRF(recordRoute(mux))))))))),
+		ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+	}
+
+	// Контекст отменяется при получении SIGINT (Ctrl+C) или SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Массивы, пролежавшие в корзине дольше RPS_TRASH_RETENTION, удаляются в фоне
+	if cfg.TrashRetention > 0 {
+		go purgeTrashPeriodically(ctx, cfg.TrashRetention)
 	}
-	return filepath.Dir(dir) // Возврат родительской директории (корень проекта)
+
+	serverErr := make(chan error, 2)
+	go func() {
+		slog.Info("Сервер запущен", "addr", cfg.Addr, "tls", tlsConfig != nil) // Сообщение о запуске сервера
+		if tlsConfig != nil {
+			serverErr <- server.ListenAndServeTLS("", "") // сертификат уже в TLSConfig
+		} else {
+			serverErr <- server.ListenAndServe()
+		}
+	}()
+
+	// Перенаправление с HTTP на HTTPS
+	var redirectServer *http.Server
+	if cfg.HTTPRedirectAddr != "" {
+		redirectServer = &http.Server{
+			Addr:         cfg.HTTPRedirectAddr,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
+			Handler:      redirectToHTTPS(cfg.Addr),
+			ErrorLog:     server.ErrorLog,
+		}
+		go func() {
+			slog.Info("Перенаправление на HTTPS запущено", "addr", cfg.HTTPRedirectAddr)
+			serverErr <- redirectServer.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			fatal("Ошибка HTTP-сервера", err)
+		}
+	case <-ctx.Done():
+	}
+
+	// Перестаем считаться готовыми и ждем завершения активных запросов
+	slog.Info("Получен сигнал остановки, завершаем активные запросы")
+	shuttingDown.Store(true)
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Ошибка остановки сервера", "error", err)
+	}
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Ошибка остановки сервера перенаправления", "error", err)
+		}
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Ошибка выгрузки спанов", "error", err)
+	}
+
+	slog.Info("Сервер остановлен")
 }
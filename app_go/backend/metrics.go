@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Метрики в текстовом формате Prometheus. Реализован только тот минимум
+// (счетчики и гистограммы с метками), который нужен серверу
+
+var (
+	httpRequestsTotal = newCounterVec("rps_http_requests_total",
+		"Количество HTTP-запросов по маршрутам", "route", "method", "status")
+	httpRequestDuration = newHistogramVec("rps_http_request_duration_seconds",
+		"Длительность обработки HTTP-запросов", latencyBuckets, "route", "method")
+
+	arrayOperationsTotal = newCounterVec("rps_array_operations_total",
+		"Количество успешных операций с массивами по видам операций", "operation")
+	sortDuration = newHistogramVec("rps_sort_duration_seconds",
+		"Время сортировки массива", latencyBuckets, "algorithm")
+	sortArrayLength = newHistogramVec("rps_sort_array_length",
+		"Длина сортируемых массивов", lengthBuckets, "algorithm")
+
+	reindexDuration = newHistogramVec("rps_reindex_duration_seconds",
+		"Время переиндексации таблицы arrays", latencyBuckets)
+)
+
+var (
+	latencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	lengthBuckets  = []float64{1, 10, 50, 100, 500, 1000, 5000, 10000, 50000, 100000}
+)
+
+// metricsHandler отдает все метрики в формате Prometheus
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	httpRequestsTotal.write(bw)
+	httpRequestDuration.write(bw)
+	arrayOperationsTotal.write(bw)
+	sortDuration.write(bw)
+	sortArrayLength.write(bw)
+	reindexDuration.write(bw)
+	rateLimitedTotal.write(bw)
+	writeDBStats(bw)
+	bw.Flush()
+}
+
+// measureRequests считает запросы и время их обработки по шаблону маршрута ServeMux
+func measureRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		// Маршрут (а не путь) ограничивает число значений метки
+		route := requestRoute(r.Context())
+		if route == "" {
+			route = "unmatched"
+		}
+		method := requestMethod(r.Method)
+		httpRequestsTotal.inc(route, method, strconv.Itoa(rec.status))
+		httpRequestDuration.observe(time.Since(start).Seconds(), route, method)
+	})
+}
+
+// knownMethods - методы, которые попадают в метки как есть
+var knownMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+
+// requestMethod - значение метки method: клиент может прислать любой метод, а каждое новое
+// значение метки - новый ряд в памяти, поэтому остальные методы сводятся к OTHER
+func requestMethod(method string) string {
+	if slices.Contains(knownMethods, method) {
+		return method
+	}
+	return "OTHER"
+}
+
+// writeDBStats выводит состояние пула соединений db.Stats()
+func writeDBStats(w io.Writer) {
+	if db == nil {
+		return
+	}
+	s := db.Stats()
+	writeSimple(w, "rps_db_max_open_connections", "gauge", "Максимальное число открытых соединений", float64(s.MaxOpenConnections))
+	writeSimple(w, "rps_db_open_connections", "gauge", "Открытые соединения", float64(s.OpenConnections))
+	writeSimple(w, "rps_db_in_use_connections", "gauge", "Соединения, занятые запросами", float64(s.InUse))
+	writeSimple(w, "rps_db_idle_connections", "gauge", "Простаивающие соединения", float64(s.Idle))
+	writeSimple(w, "rps_db_wait_count_total", "counter", "Сколько раз запрос ждал свободное соединение", float64(s.WaitCount))
+	writeSimple(w, "rps_db_wait_duration_seconds_total", "counter", "Суммарное время ожидания соединения", s.WaitDuration.Seconds())
+	writeSimple(w, "rps_db_max_idle_closed_total", "counter", "Соединения, закрытые из-за SetMaxIdleConns", float64(s.MaxIdleClosed))
+	writeSimple(w, "rps_db_max_idle_time_closed_total", "counter", "Соединения, закрытые из-за SetConnMaxIdleTime", float64(s.MaxIdleTimeClosed))
+	writeSimple(w, "rps_db_max_lifetime_closed_total", "counter", "Соединения, закрытые из-за SetConnMaxLifetime", float64(s.MaxLifetimeClosed))
+}
+
+func writeSimple(w io.Writer, name, typ, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, typ, name, formatFloat(value))
+}
+
+// counterVec - счетчик с набором меток
+type counterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]float64
+	series map[string][]string // ключ -> значения меток
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+		series: make(map[string][]string),
+	}
+}
+
+func (c *counterVec) inc(labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.series[key]; !ok {
+		c.series[key] = labelValues
+	}
+	c.values[key]++
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range sortedKeys(c.series) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, c.series[key]), formatFloat(c.values[key]))
+	}
+}
+
+// histogramVec - гистограмма с набором меток
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	labelValues []string
+	counts      []uint64 // counts[i] - наблюдения <= buckets[i] (не накопительно)
+	count       uint64
+	sum         float64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*histogram),
+	}
+}
+
+func (h *histogramVec) observe(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += value
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		names := append(append([]string{}, h.labels...), "le")
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			values := append(append([]string{}, s.labelValues...), formatFloat(bound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(names, values), cumulative)
+		}
+		values := append(append([]string{}, s.labelValues...), "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(names, values), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, s.labelValues), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, s.labelValues), s.count)
+	}
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteString(`="`)
+		sb.WriteString(labelEscaper.Replace(values[i]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
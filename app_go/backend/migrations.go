@@ -0,0 +1,260 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// migration - шаг изменения схемы БД; версии идут по порядку, начиная с 1
+type migration struct {
+	version    int
+	name       string
+	statements []string
+}
+
+// migrations - все изменения схемы. Уже примененные шаги не меняются,
+// новые добавляются в конец списка
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create_arrays",
+		statements: []string{`
+			CREATE TABLE IF NOT EXISTS arrays (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				array_data TEXT NOT NULL,
+				is_sorted BOOLEAN DEFAULT FALSE,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		},
+	},
+	{
+		version: 2,
+		name:    "users_and_array_owners",
+		statements: []string{`
+			CREATE TABLE IF NOT EXISTS users (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				username VARCHAR(64) NOT NULL UNIQUE,
+				password_hash VARCHAR(255) NOT NULL,
+				role VARCHAR(16) NOT NULL DEFAULT 'user',
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`, `
+			CREATE TABLE IF NOT EXISTS sessions (
+				token_hash CHAR(64) PRIMARY KEY,
+				user_id INT NOT NULL,
+				expires_at DATETIME NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				INDEX idx_sessions_user (user_id),
+				CONSTRAINT fk_sessions_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			// Массивы, созданные до появления пользователей, остаются без владельца - их видит только администратор
+			`ALTER TABLE arrays ADD COLUMN owner_id INT NULL`,
+			`ALTER TABLE arrays ADD INDEX idx_arrays_owner (owner_id)`,
+			`ALTER TABLE arrays ADD CONSTRAINT fk_arrays_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE`,
+		},
+	},
+	{
+		version: 3,
+		name:    "api_keys_and_revoked_tokens",
+		statements: []string{`
+			CREATE TABLE IF NOT EXISTS api_keys (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				user_id INT NOT NULL,
+				name VARCHAR(100) NOT NULL,
+				key_prefix VARCHAR(16) NOT NULL,
+				key_hash CHAR(64) NOT NULL UNIQUE,
+				scopes VARCHAR(255) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				expires_at DATETIME NULL,
+				last_used_at DATETIME NULL,
+				revoked_at DATETIME NULL,
+				INDEX idx_api_keys_user (user_id),
+				CONSTRAINT fk_api_keys_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`, `
+			CREATE TABLE IF NOT EXISTS revoked_tokens (
+				jti CHAR(32) PRIMARY KEY,
+				expires_at DATETIME NOT NULL
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		},
+	},
+	{
+		version: 4,
+		name:    "audit_log",
+		// Журнал только дополняется; внешнего ключа на users нет, чтобы записи
+		// переживали удаление пользователя (имя сохраняется в actor)
+		statements: []string{`
+			CREATE TABLE IF NOT EXISTS audit_log (
+				id BIGINT AUTO_INCREMENT PRIMARY KEY,
+				created_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+				actor_id INT NULL,
+				actor VARCHAR(64) NOT NULL,
+				auth_method VARCHAR(16) NOT NULL DEFAULT '',
+				action VARCHAR(32) NOT NULL,
+				array_id INT NULL,
+				before_data MEDIUMTEXT NULL,
+				after_data MEDIUMTEXT NULL,
+				request_id VARCHAR(64) NOT NULL DEFAULT '',
+				INDEX idx_audit_created (created_at),
+				INDEX idx_audit_actor (actor_id),
+				INDEX idx_audit_action (action),
+				INDEX idx_audit_array (array_id)
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		},
+	},
+	{
+		version: 5,
+		name:    "arrays_soft_delete",
+		// Удаленные массивы остаются в таблице до очистки корзины
+		statements: []string{
+			`ALTER TABLE arrays ADD COLUMN deleted_at DATETIME NULL`,
+			`ALTER TABLE arrays ADD INDEX idx_arrays_deleted (deleted_at)`,
+		},
+	},
+	{
+		version: 6,
+		name:    "array_versions",
+		// Сортировка и изменения создают новую версию того же массива; история следует
+		// за ID при переиндексации и удаляется вместе с массивом
+		statements: []string{
+			`ALTER TABLE arrays ADD COLUMN version INT NOT NULL DEFAULT 1`,
+			`
+			CREATE TABLE IF NOT EXISTS array_versions (
+				id BIGINT AUTO_INCREMENT PRIMARY KEY,
+				array_id INT NOT NULL,
+				version INT NOT NULL,
+				array_data TEXT NOT NULL,
+				is_sorted BOOLEAN NOT NULL DEFAULT FALSE,
+				action VARCHAR(16) NOT NULL,
+				algorithm VARCHAR(32) NULL,
+				reverted_from INT NULL,
+				author_id INT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE KEY uq_array_versions (array_id, version),
+				CONSTRAINT fk_array_versions_array FOREIGN KEY (array_id) REFERENCES arrays(id)
+					ON UPDATE CASCADE ON DELETE CASCADE
+			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			`
+			INSERT INTO array_versions (array_id, version, array_data, is_sorted, action, author_id, created_at)
+			SELECT id, 1, array_data, is_sorted, 'create', owner_id, created_at FROM arrays`,
+		},
+	},
+	{
+		version: 7,
+		name:    "array_summary",
+		// Сводка по элементам обновляется при каждой записи массива; строки, записанные раньше,
+		// заполняет backfillArraySummaries при старте сервера (NULL - сводка не вычислена)
+		statements: []string{
+			`
+			ALTER TABLE arrays
+				ADD COLUMN length INT NULL,
+				ADD COLUMN sum DECIMAL(40, 0) NULL,
+				ADD COLUMN min_value BIGINT NULL,
+				ADD COLUMN max_value BIGINT NULL,
+				ADD COLUMN mean DOUBLE NULL`,
+			`ALTER TABLE arrays ADD INDEX idx_arrays_length (length)`,
+		},
+	},
+	{
+		version: 8,
+		name:    "array_elements",
+		// Элементы массивов по одному в строке - индекс для поиска по содержимому. Следует за ID
+		// при переиндексации и удаляется вместе с массивом; заполняется при записи массива,
+		// для сохраненных раньше - backfillArrayElements при старте сервера
+		statements: []string{
+			`
+			CREATE TABLE IF NOT EXISTS array_elements (
+				array_id INT NOT NULL,
+				position INT NOT NULL,
+				value BIGINT NOT NULL,
+				PRIMARY KEY (array_id, position),
+				INDEX idx_array_elements_value (value, array_id),
+				CONSTRAINT fk_array_elements_array FOREIGN KEY (array_id) REFERENCES arrays(id)
+					ON UPDATE CASCADE ON DELETE CASCADE
+			) ENGINE=InnoDB`,
+			`ALTER TABLE arrays ADD INDEX idx_arrays_range (min_value, max_value)`,
+		},
+	},
+	{
+		version: 9,
+		name:    "array_content_hash",
+		// content_hash - SHA-256 содержимого, elements_hash - отсортированного содержимого;
+		// для сохраненных раньше массивов заполняются при старте сервера
+		statements: []string{
+			`
+			ALTER TABLE arrays
+				ADD COLUMN content_hash CHAR(64) NULL,
+				ADD COLUMN elements_hash CHAR(64) NULL,
+				ADD INDEX idx_arrays_owner_hash (owner_id, content_hash),
+				ADD INDEX idx_arrays_content_hash (content_hash),
+				ADD INDEX idx_arrays_elements_hash (elements_hash)`,
+		},
+	},
+	{
+		version: 10,
+		name:    "array_order",
+		// Упорядоченность (analyzeOrder) вычисляется при записи, а не при каждом чтении списка;
+		// для сохраненных раньше массивов заполняется при старте сервера
+		statements: []string{
+			`
+			ALTER TABLE arrays
+				ADD COLUMN sort_order VARCHAR(16) NULL,
+				ADD COLUMN inversions BIGINT NULL,
+				ADD COLUMN nearly_sorted BOOLEAN NULL`,
+		},
+	},
+}
+
+// applyMigrations применяет к БД все еще не примененные миграции
+func applyMigrations(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблицы миграций: %v", err)
+	}
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		// DDL в MySQL не откатывается транзакцией, поэтому шаги выполняются по одному,
+		// а версия записывается только после успешного выполнения всех шагов
+		for _, stmt := range m.statements {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("ошибка миграции %d (%s): %v", m.version, m.name, err)
+			}
+		}
+		if _, err := db.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.version, m.name); err != nil {
+			return fmt.Errorf("ошибка записи версии миграции %d: %v", m.version, err)
+		}
+		slog.Info("Применена миграция", "version", m.version, "name", m.name)
+	}
+
+	return nil
+}
+
+// schemaVersion возвращает номер последней примененной миграции
+func schemaVersion(db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("ошибка чтения версии схемы: %v", err)
+	}
+	return int(version.Int64), nil
+}
+
+// latestSchemaVersion - версия схемы, которую ожидает текущая сборка сервера
+func latestSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].version
+}
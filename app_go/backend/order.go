@@ -0,0 +1,83 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Порядок элементов массива
+const (
+	orderAscending  = "asc"      // по неубыванию
+	orderDescending = "desc"     // по невозрастанию
+	orderConstant   = "constant" // все элементы равны (или один элемент)
+	orderNone       = "none"     // не упорядочен
+)
+
+// codeSortedFlagMismatch - клиент передал isSorted: true для неотсортированного массива
+const codeSortedFlagMismatch = "sorted_flag_mismatch"
+
+// orderInfo - упорядоченность массива, вычисленная сервером
+type orderInfo struct {
+	IsSorted     bool   `json:"is_sorted"` // по неубыванию, как после сортировки
+	Order        string `json:"order"`
+	Inversions   int64  `json:"inversions"`    // пары i < j с a[i] > a[j]
+	NearlySorted bool   `json:"nearly_sorted"` // не отсортирован, но инверсий не больше половины длины массива
+}
+
+// isSortedAsc - признак is_sorted, который сервер сохраняет при каждой записи массива
+func isSortedAsc(numbers []int) bool {
+	return slices.IsSorted(numbers)
+}
+
+// analyzeOrder определяет порядок массива и число инверсий. При числе инверсий порядка n
+// сортировка вставками работает за линейное время; массив с не более чем n/2 инверсиями
+// (и не упорядоченный по убыванию) считается почти отсортированным
+func analyzeOrder(numbers []int) orderInfo {
+	info := orderInfo{IsSorted: isSortedAsc(numbers), Order: orderNone}
+	desc := slices.IsSortedFunc(numbers, func(a, b int) int { return cmp.Compare(b, a) })
+	switch {
+	case info.IsSorted && desc:
+		info.Order = orderConstant
+	case info.IsSorted:
+		info.Order = orderAscending
+	case desc:
+		info.Order = orderDescending
+	}
+	if !info.IsSorted {
+		info.Inversions = countInversions(numbers)
+		info.NearlySorted = info.Order != orderDescending && info.Inversions <= int64(len(numbers)/2)
+	}
+	return info
+}
+
+// countInversions считает инверсии сортировкой слиянием копии массива за O(n log n)
+func countInversions(numbers []int) int64 {
+	a := slices.Clone(numbers)
+	buf := make([]int, len(a))
+	var count func(lo, hi int) int64
+	count = func(lo, hi int) int64 {
+		if hi-lo < 2 {
+			return 0
+		}
+		mid := (lo + hi) / 2
+		n := count(lo, mid) + count(mid, hi)
+		i, j, k := lo, mid, lo
+		for i < mid && j < hi {
+			if a[j] < a[i] {
+				// a[j] меньше всех оставшихся элементов левой половины
+				n += int64(mid - i)
+				buf[k] = a[j]
+				j++
+			} else {
+				buf[k] = a[i]
+				i++
+			}
+			k++
+		}
+		k += copy(buf[k:], a[i:mid])
+		copy(buf[k:], a[j:hi])
+		copy(a[lo:hi], buf[lo:hi])
+		return n
+	}
+	return count(0, len(a))
+}
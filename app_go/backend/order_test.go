@@ -0,0 +1,53 @@
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func TestCountInversions(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	inputs := [][]int{{}, {1}, {1, 2}, {2, 1}, {3, 2, 1}, {2, 2, 2}, {1, 3, 2, 3, 1}}
+	for range 20 {
+		a := make([]int, rng.IntN(100))
+		for i := range a {
+			a[i] = rng.IntN(10) - 5
+		}
+		inputs = append(inputs, a)
+	}
+
+	for _, in := range inputs {
+		var want int64
+		for i := range in {
+			for j := i + 1; j < len(in); j++ {
+				if in[i] > in[j] {
+					want++
+				}
+			}
+		}
+		if got := countInversions(in); got != want {
+			t.Fatalf("countInversions(%v) = %d, ожидалось %d", in, got, want)
+		}
+	}
+}
+
+func TestAnalyzeOrder(t *testing.T) {
+	cases := []struct {
+		in   []int
+		want orderInfo
+	}{
+		{[]int{}, orderInfo{IsSorted: true, Order: orderConstant}},
+		{[]int{7}, orderInfo{IsSorted: true, Order: orderConstant}},
+		{[]int{4, 4, 4}, orderInfo{IsSorted: true, Order: orderConstant}},
+		{[]int{1, 2, 2, 5}, orderInfo{IsSorted: true, Order: orderAscending}},
+		{[]int{5, 3, 3, 1}, orderInfo{Order: orderDescending, Inversions: 5}},
+		{[]int{1, 3, 2, 4}, orderInfo{Order: orderNone, Inversions: 1, NearlySorted: true}},
+		{[]int{2, 1}, orderInfo{Order: orderDescending, Inversions: 1}},
+		{[]int{3, 1, 2, 0}, orderInfo{Order: orderNone, Inversions: 5}},
+	}
+	for _, c := range cases {
+		if got := analyzeOrder(c.in); got != c.want {
+			t.Errorf("analyzeOrder(%v) = %+v, ожидалось %+v", c.in, got, c.want)
+		}
+	}
+}
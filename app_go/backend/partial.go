@@ -0,0 +1,280 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Способы частичной сортировки
+const (
+	partialHeap        = "heap"        // куча из k элементов, O(n log k); повторяет std::partial_sort
+	partialQuickselect = "quickselect" // быстрый выбор k-го элемента и сортировка k первых, в среднем O(n + k log k)
+)
+
+// PartialSortRequest - параметры top-k и частичной сортировки
+type PartialSortRequest struct {
+	K      int    `json:"k"`
+	Order  string `json:"order,omitempty"`  // asc (по умолчанию) - k наименьших, desc - k наибольших
+	Method string `json:"method,omitempty"` // heap (по умолчанию) или quickselect
+	Save   string `json:"save,omitempty"`   // none, new или (для частичной сортировки) version
+}
+
+// siftDownFunc - siftDown с произвольным сравнением: в корне кучи heap - наибольший элемент в смысле less
+func siftDownFunc(heap []int, i int, less func(a, b int) bool) {
+	for {
+		largest := i
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(heap) && less(heap[largest], heap[child]) {
+				largest = child
+			}
+		}
+		if largest == i {
+			return
+		}
+		heap[i], heap[largest] = heap[largest], heap[i]
+		i = largest
+	}
+}
+
+// partialSortHeap переставляет numbers так, что первые k элементов - наименьшие в смысле less
+// и упорядочены, как std::partial_sort: куча из первых k элементов, в которую проталкиваются
+// меньшие элементы остатка, затем сортировка кучи
+func partialSortHeap(numbers []int, k int, less func(a, b int) bool) {
+	heap := numbers[:k]
+	for i := k/2 - 1; i >= 0; i-- {
+		siftDownFunc(heap, i, less)
+	}
+	for i := k; i < len(numbers); i++ {
+		if less(numbers[i], heap[0]) {
+			numbers[i], heap[0] = heap[0], numbers[i]
+			siftDownFunc(heap, 0, less)
+		}
+	}
+	for end := k - 1; end > 0; end-- {
+		heap[0], heap[end] = heap[end], heap[0]
+		siftDownFunc(heap[:end], 0, less)
+	}
+}
+
+// partialSortQuickselect - то же, что partialSortHeap, через quickselect: k наименьших (asc)
+// или наибольших (desc) элементов переносятся в начало и сортируются
+func partialSortQuickselect(numbers []int, k int, order string) {
+	if order == orderDescending {
+		// k наибольших - хвост после выбора (n-k)-го по возрастанию элемента
+		n := len(numbers)
+		quickselect(numbers, n-k)
+		copy(numbers, slices.Concat(numbers[n-k:], numbers[:n-k]))
+		slices.SortFunc(numbers[:k], func(a, b int) int { return cmp.Compare(b, a) })
+		return
+	}
+	quickselect(numbers, k-1)
+	slices.Sort(numbers[:k])
+}
+
+// partialSort возвращает копию numbers, в которой первые k элементов упорядочены по order
+// и являются k наименьшими (asc) или наибольшими (desc); порядок остальных не определен
+func partialSort(numbers []int, k int, order, method string) []int {
+	result := slices.Clone(numbers)
+	if method == partialQuickselect {
+		partialSortQuickselect(result, k, order)
+		return result
+	}
+	less := func(a, b int) bool { return a < b }
+	if order == orderDescending {
+		less = func(a, b int) bool { return a > b }
+	}
+	partialSortHeap(result, k, less)
+	return result
+}
+
+// decodePartialSort читает id, тело запроса и политику повторов для save=new; saves - допустимые
+// значения save, первое - по умолчанию. При ошибке ответ уже отправлен
+func decodePartialSort(w http.ResponseWriter, r *http.Request, saves ...string) (int, PartialSortRequest, string, bool) {
+	var req PartialSortRequest
+	if r.Method != "POST" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return 0, req, "", false
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Неверный ID массива",
+		}, http.StatusBadRequest)
+		return 0, req, "", false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return 0, req, "", false
+	}
+	policy, err := duplicatesPolicy(r)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return 0, req, "", false
+	}
+
+	if req.Order == "" {
+		req.Order = orderAscending
+	}
+	if req.Method == "" {
+		req.Method = partialHeap
+	}
+	if req.Save == "" {
+		req.Save = saves[0]
+	}
+	var message string
+	switch {
+	case req.K < 1:
+		message = "k должен быть положительным"
+	case req.Order != orderAscending && req.Order != orderDescending:
+		message = fmt.Sprintf("Неизвестный порядок %q, доступны: asc, desc", req.Order)
+	case req.Method != partialHeap && req.Method != partialQuickselect:
+		message = fmt.Sprintf("Неизвестный способ %q, доступны: heap, quickselect", req.Method)
+	case !slices.Contains(saves, req.Save):
+		message = fmt.Sprintf("Неизвестное значение save %q, доступны: %s", req.Save, strings.Join(saves, ", "))
+	}
+	if message != "" {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: message,
+		}, http.StatusBadRequest)
+		return 0, req, "", false
+	}
+	return id, req, policy, true
+}
+
+// checkK проверяет, что k не больше длины массива
+func checkK(k, length int) error {
+	if k > length {
+		return fmt.Errorf("%w: k = %d больше длины массива %d", errInvalidEdit, k, length)
+	}
+	return nil
+}
+
+// tracedPartialSort - partialSort со спаном трассировки
+func tracedPartialSort(r *http.Request, numbers []int, req PartialSortRequest) []int {
+	_, span := startSpan(r.Context(), "partialSort",
+		attribute.String("partial.method", req.Method),
+		attribute.Int("partial.k", req.K),
+		attribute.Int("array.length", len(numbers)))
+	defer span.End()
+	return partialSort(numbers, req.K, req.Order, req.Method)
+}
+
+// topKHandler - POST /arrays/topk?id=1 {"k": 10, "order": "desc"}: k наименьших (asc) или наибольших (desc)
+// элементов массива по порядку. save=none (по умолчанию) только возвращает их, save=new сохраняет новым массивом
+func topKHandler(w http.ResponseWriter, r *http.Request) {
+	id, req, policy, ok := decodePartialSort(w, r, transformSaveNone, transformSaveNew)
+	if !ok {
+		return
+	}
+
+	u := currentUser(r.Context())
+	numbers, err := getArrayByID(r.Context(), u, id)
+	if err == nil {
+		err = checkK(req.K, len(numbers))
+	}
+	if err != nil {
+		writeEditError(w, err)
+		return
+	}
+
+	result := tracedPartialSort(r, numbers, req)[:req.K]
+	data := map[string]interface{}{"source": id, "k": req.K, "order": req.Order, "method": req.Method, "array": result}
+	resp := Response{
+		Success: true,
+		Data:    data,
+	}
+	status := http.StatusOK
+	if req.Save == transformSaveNew {
+		newID, existing, err := createArray(r.Context(), u.ID, result, policy, auditEntry{
+			Action: auditSave,
+			Before: map[string]interface{}{"source": id, "top_k": req},
+		})
+		if err != nil {
+			writeCreateError(w, err)
+			return
+		}
+		data["id"] = newID
+		status = createdStatus(&resp, newID, existing)
+	}
+
+	arrayOperationsTotal.inc("topk")
+
+	jsonResponse(w, resp, status)
+}
+
+// partialSortHandler - POST /arrays/partial-sort?id=1 {"k": 10}: весь массив, в котором первые k элементов -
+// наименьшие (asc) или наибольшие (desc) по порядку, а остальные идут после них в неопределенном порядке.
+// save=version (по умолчанию) сохраняет результат новой версией, new - новым массивом, none - только возвращает
+func partialSortHandler(w http.ResponseWriter, r *http.Request) {
+	id, req, policy, ok := decodePartialSort(w, r, transformSaveVersion, transformSaveNew, transformSaveNone)
+	if !ok {
+		return
+	}
+
+	u := currentUser(r.Context())
+	data := map[string]interface{}{"source": id, "k": req.K, "order": req.Order, "method": req.Method}
+	var result []int
+	var err error
+	if req.Save == transformSaveVersion {
+		var version int
+		change := versionChange{Action: versionSort, Algorithm: "partial_" + req.Method}
+		version, result, err = editArray(r.Context(), u, id, change, func(current arraySnapshot) ([]int, error) {
+			if current.Array == nil {
+				return nil, errInvalidArrayData
+			}
+			if err := checkK(req.K, len(current.Array)); err != nil {
+				return nil, err
+			}
+			return tracedPartialSort(r, current.Array, req), nil
+		})
+		data["id"], data["version"] = id, version
+	} else {
+		var numbers []int
+		if numbers, err = getArrayByID(r.Context(), u, id); err == nil {
+			err = checkK(req.K, len(numbers))
+		}
+		if err == nil {
+			result = tracedPartialSort(r, numbers, req)
+		}
+	}
+	if err != nil {
+		writeEditError(w, err)
+		return
+	}
+
+	resp := Response{
+		Success: true,
+		Data:    data,
+	}
+	status := http.StatusOK
+	if req.Save == transformSaveNew {
+		newID, existing, err := createArray(r.Context(), u.ID, result, policy, auditEntry{
+			Action: auditSave,
+			Before: map[string]interface{}{"source": id, "partial_sort": req},
+		})
+		if err != nil {
+			writeCreateError(w, err)
+			return
+		}
+		data["id"] = newID
+		status = createdStatus(&resp, newID, existing)
+	}
+
+	arrayOperationsTotal.inc("partial_sort")
+
+	data["array"], data["portion"] = result, result[:req.K]
+	jsonResponse(w, resp, status)
+}
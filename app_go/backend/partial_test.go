@@ -0,0 +1,46 @@
+package main
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestPartialSort(t *testing.T) {
+	rng := rand.New(rand.NewPCG(7, 8))
+	inputs := [][]int{{1}, {2, 1}, {3, 3, 3}, {5, -2, 9, 0, -2, 7}}
+	for range 10 {
+		a := make([]int, 1+rng.IntN(100))
+		for i := range a {
+			a[i] = rng.IntN(20) - 10
+		}
+		inputs = append(inputs, a)
+	}
+
+	for _, in := range inputs {
+		for _, order := range []string{orderAscending, orderDescending} {
+			want := slices.Clone(in)
+			slices.Sort(want)
+			if order == orderDescending {
+				slices.SortFunc(want, func(a, b int) int { return cmp.Compare(b, a) })
+			}
+			for _, method := range []string{partialHeap, partialQuickselect} {
+				for _, k := range []int{1, len(in) / 2, len(in)} {
+					if k < 1 {
+						continue
+					}
+					got := partialSort(in, k, order, method)
+					if !slices.Equal(got[:k], want[:k]) {
+						t.Fatalf("%s %s k=%d (%v): первые k = %v, ожидалось %v", method, order, k, in, got[:k], want[:k])
+					}
+					rest := slices.Clone(got)
+					slices.Sort(rest)
+					if sorted := slices.Sorted(slices.Values(in)); !slices.Equal(rest, sorted) {
+						t.Fatalf("%s %s k=%d: элементы массива изменились: %v", method, order, k, got)
+					}
+				}
+			}
+		}
+	}
+}
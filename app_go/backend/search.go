@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+
+	// elementsBatchSize - строк array_elements в одном INSERT (по 3 параметра на строку)
+	elementsBatchSize = 1000
+)
+
+// writeArrayElements заменяет строки array_elements массива id его текущими элементами.
+// Таблица элементов - индекс для поиска по содержимому, сам массив хранится в arrays.array_data
+func writeArrayElements(ctx context.Context, tx *sql.Tx, id int64, numbers []int) error {
+	if _, err := execTx(ctx, tx, "writeArrayElements.delete", "DELETE FROM array_elements WHERE array_id = ?", id); err != nil {
+		return err
+	}
+	for start := 0; start < len(numbers); start += elementsBatchSize {
+		batch := numbers[start:min(start+elementsBatchSize, len(numbers))]
+		args := make([]interface{}, 0, 3*len(batch))
+		for i, v := range batch {
+			args = append(args, id, start+i, v)
+		}
+		query := "INSERT INTO array_elements (array_id, position, value) VALUES (?, ?, ?)" +
+			strings.Repeat(", (?, ?, ?)", len(batch)-1)
+		if _, err := execTx(ctx, tx, "writeArrayElements.insert", query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillArrayElements заполняет таблицу элементов для массивов, сохраненных до ее появления
+func backfillArrayElements(ctx context.Context) (int, error) {
+	query := `
+		SELECT a.id, a.array_data FROM arrays a
+		WHERE NOT EXISTS (SELECT 1 FROM array_elements e WHERE e.array_id = a.id)`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, storeError(ctx, "backfillArrayElements", err)
+	}
+	pending := map[int64][]int{}
+	for rows.Next() {
+		var id int64
+		var arrayData string
+		if err := rows.Scan(&id, &arrayData); err != nil {
+			rows.Close()
+			return 0, storeError(ctx, "backfillArrayElements", err)
+		}
+		// Поврежденные строки в индекс не попадают
+		if numbers, err := parseArrayString(arrayData); err == nil {
+			pending[id] = numbers
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, storeError(ctx, "backfillArrayElements", err)
+	}
+
+	for id, numbers := range pending {
+		err := inTx(ctx, "backfillArrayElements", func(ctx context.Context, tx *sql.Tx) error {
+			return writeArrayElements(ctx, tx, id, numbers)
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(pending), nil
+}
+
+// arraySearch - условия поиска по содержимому, объединяются через И
+type arraySearch struct {
+	All         []int // содержит все значения (contains - одно значение)
+	Any         []int // содержит хотя бы одно значение
+	Subsequence []int // содержит значения в этом порядке (между ними могут быть другие элементы)
+	Contiguous  bool  // Subsequence должна идти подряд
+	Min         *int  // все элементы не меньше Min
+	Max         *int  // все элементы не больше Max
+	Limit       int
+}
+
+// query строит отбор для queryArrays. Наличие значений проверяется по индексу array_elements,
+// диапазон - по столбцам сводки min_value/max_value; порядок подпоследовательности проверяется
+// уже по содержимому найденных массивов
+func (s arraySearch) query() arrayQuery {
+	var conds []string
+	var args []interface{}
+	placeholders := func(values []int) string {
+		for _, v := range values {
+			args = append(args, v)
+		}
+		return "?" + strings.Repeat(", ?", len(values)-1)
+	}
+
+	// Для подпоследовательности каждое ее значение должно встречаться в массиве
+	required := slices.Concat(s.All, s.Subsequence)
+	slices.Sort(required)
+	required = slices.Compact(required)
+	if len(required) > 0 {
+		conds = append(conds, `a.id IN (
+			SELECT e.array_id FROM array_elements e
+			WHERE e.value IN (`+placeholders(required)+`)
+			GROUP BY e.array_id
+			HAVING COUNT(DISTINCT e.value) = ?)`)
+		args = append(args, len(required))
+	}
+	if len(s.Any) > 0 {
+		conds = append(conds, `EXISTS (
+			SELECT 1 FROM array_elements e
+			WHERE e.array_id = a.id AND e.value IN (`+placeholders(s.Any)+`))`)
+	}
+	if s.Min != nil {
+		conds = append(conds, "a.min_value >= ?")
+		args = append(args, *s.Min)
+	}
+	if s.Max != nil {
+		conds = append(conds, "a.max_value <= ?")
+		args = append(args, *s.Max)
+	}
+
+	q := arrayQuery{Filter: strings.Join(conds, " AND "), Args: args, Limit: s.Limit}
+	if len(s.Subsequence) > 0 {
+		q.Keep = func(numbers []int) bool {
+			if s.Contiguous {
+				return containsRun(numbers, s.Subsequence)
+			}
+			return containsSubsequence(numbers, s.Subsequence)
+		}
+	}
+	return q
+}
+
+// containsSubsequence - sub встречается в numbers в том же порядке, возможно с пропусками
+func containsSubsequence(numbers, sub []int) bool {
+	i := 0
+	for _, v := range numbers {
+		if i < len(sub) && v == sub[i] {
+			i++
+		}
+	}
+	return i == len(sub)
+}
+
+// containsRun - sub встречается в numbers подряд
+func containsRun(numbers, sub []int) bool {
+	for i := 0; i+len(sub) <= len(numbers); i++ {
+		if slices.Equal(numbers[i:i+len(sub)], sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchArraysHandler - поиск массивов по содержимому:
+// contains, all, any, subsequence (+ contiguous=true), min, max, limit
+func searchArraysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s, err := parseArraySearch(r)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	arrays, err := queryArrays(r.Context(), currentUser(r.Context()), "searchArrays", s.query())
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка поиска массивов: %v", err),
+		}, http.StatusInternalServerError)
+		return
+	}
+	if arrays == nil {
+		arrays = []map[string]interface{}{}
+	}
+
+	arrayOperationsTotal.inc("search")
+
+	jsonResponse(w, Response{
+		Success: true,
+		Data:    arrays,
+	}, http.StatusOK)
+}
+
+func parseArraySearch(r *http.Request) (arraySearch, error) {
+	q := r.URL.Query()
+	s := arraySearch{Limit: defaultSearchLimit, Contiguous: q.Get("contiguous") == "true"}
+
+	lists := []struct {
+		name string
+		dst  *[]int
+	}{{"contains", &s.All}, {"all", &s.All}, {"any", &s.Any}, {"subsequence", &s.Subsequence}}
+	for _, p := range lists {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		values, err := parseArrayString(v)
+		if err != nil {
+			return s, fmt.Errorf("неверное значение %s: %v", p.name, err)
+		}
+		*p.dst = append(*p.dst, values...)
+	}
+
+	bounds := []struct {
+		name string
+		dst  **int
+	}{{"min", &s.Min}, {"max", &s.Max}}
+	for _, p := range bounds {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return s, fmt.Errorf("неверное значение %s: %q", p.name, v)
+		}
+		*p.dst = &n
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxSearchLimit {
+			return s, fmt.Errorf("limit должен быть от 1 до %d", maxSearchLimit)
+		}
+		s.Limit = n
+	}
+
+	if len(s.All) == 0 && len(s.Any) == 0 && len(s.Subsequence) == 0 && s.Min == nil && s.Max == nil {
+		return s, fmt.Errorf("укажите условие поиска: contains, all, any, subsequence, min или max")
+	}
+	return s, nil
+}
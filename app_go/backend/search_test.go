@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestContainsSubsequence(t *testing.T) {
+	cases := []struct {
+		numbers, sub []int
+		subseq, run  bool
+	}{
+		{[]int{1, 2, 3}, nil, true, true},
+		{nil, []int{1}, false, false},
+		{[]int{1, 2, 3}, []int{1, 2, 3}, true, true},
+		{[]int{1, 2, 3}, []int{2, 3}, true, true},
+		{[]int{1, 5, 2, 7, 3}, []int{1, 2, 3}, true, false},
+		{[]int{1, 2, 3}, []int{3, 2}, false, false},
+		{[]int{1, 2, 3}, []int{1, 2, 3, 4}, false, false},
+		{[]int{2, 2, 1, 2}, []int{2, 2, 2}, true, false},
+		{[]int{1, 1, 2}, []int{1, 2}, true, true},
+		{[]int{-1, 0, -1}, []int{-1, -1}, true, false},
+	}
+	for _, c := range cases {
+		if got := containsSubsequence(c.numbers, c.sub); got != c.subseq {
+			t.Errorf("containsSubsequence(%v, %v) = %v, ожидалось %v", c.numbers, c.sub, got, c.subseq)
+		}
+		if got := containsRun(c.numbers, c.sub); got != c.run {
+			t.Errorf("containsRun(%v, %v) = %v, ожидалось %v", c.numbers, c.sub, got, c.run)
+		}
+	}
+}
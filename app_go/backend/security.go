@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+)
+
+const (
+	csrfHeaderName = "X-CSRF-Token"
+	codeCSRFFailed = "csrf_failed"
+)
+
+// csrfToken выводит CSRF-токен из токена сессии: страница получает его в /auth/me и
+// возвращает в заголовке X-CSRF-Token. Чужой сайт не может его узнать, не зная cookie
+func csrfToken(sessionToken string) string {
+	mac := hmac.New(sha256.New, []byte(sessionToken))
+	mac.Write([]byte("rps-csrf"))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// isSafeMethod - методы, которые не изменяют данные
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// protectCSRF требует X-CSRF-Token в изменяющих запросах, аутентифицированных cookie сессии.
+// Ключи API и bearer-токены браузер не подставляет сам, поэтому для них проверка не нужна
+func protectCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := currentUser(r.Context())
+		if u == nil || u.authMethod != authSession || isSafeMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cookie, err := r.Cookie(sessionCookieName)
+		if err != nil || !hmac.Equal([]byte(r.Header.Get(csrfHeaderName)), []byte(csrfToken(cookie.Value))) {
+			jsonResponse(w, Response{
+				Success: false,
+				Code:    codeCSRFFailed,
+				Message: "Неверный CSRF-токен, обновите страницу",
+			}, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// securityHeaders добавляет заголовки, ограничивающие браузер: источники скриптов и стилей (CSP),
+// встраивание страницы во фреймы, угадывание типа содержимого и передачу Referer
+func securityHeaders(csp string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if csp != "" {
+			h.Set("Content-Security-Policy", csp)
+		}
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY") // для браузеров без поддержки frame-ancestors
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		next.ServeHTTP(w, r)
+	})
+}
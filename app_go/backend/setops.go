@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Операции над несколькими сохраненными массивами
+const (
+	combineMerge      = "merge"      // слияние в один отсортированный массив, повторы сохраняются
+	combineUnion      = "union"      // различные значения хотя бы одного массива
+	combineIntersect  = "intersect"  // различные значения, которые есть во всех массивах
+	combineDifference = "difference" // различные значения первого массива, которых нет в остальных
+	combineConcat     = "concat"     // массивы один за другим, без сортировки
+)
+
+var combineOperations = []string{combineMerge, combineUnion, combineIntersect, combineDifference, combineConcat}
+
+// maxCombineArrays - наибольшее число массивов в одной операции
+const maxCombineArrays = 20
+
+// combineSortAlgorithm сортирует неотсортированные исходные массивы, если алгоритм не указан
+const combineSortAlgorithm = "merge"
+
+// CombineRequest - операция над сохраненными массивами IDs; результат сохраняется новым массивом
+type CombineRequest struct {
+	Operation string `json:"operation"`
+	IDs       []int  `json:"ids"`
+	Algorithm string `json:"algorithm,omitempty"` // алгоритм из реестра для неотсортированных исходных массивов
+}
+
+// mergeSorted сливает два отсортированных массива за O(len(a)+len(b))
+func mergeSorted(a, b []int) []int {
+	result := make([]int, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if b[j] < a[i] {
+			result = append(result, b[j])
+			j++
+		} else {
+			result = append(result, a[i])
+			i++
+		}
+	}
+	result = append(result, a[i:]...)
+	return append(result, b[j:]...)
+}
+
+// intersectSorted - различные значения, которые есть в обоих отсортированных массивах
+func intersectSorted(a, b []int) []int {
+	var result []int
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case b[j] < a[i]:
+			j++
+		default:
+			if len(result) == 0 || result[len(result)-1] != a[i] {
+				result = append(result, a[i])
+			}
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// differenceSorted - различные значения отсортированного a, которых нет в отсортированном b
+func differenceSorted(a, b []int) []int {
+	var result []int
+	j := 0
+	for i, v := range a {
+		if i > 0 && a[i-1] == v {
+			continue
+		}
+		for j < len(b) && b[j] < v {
+			j++
+		}
+		if j == len(b) || b[j] != v {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// combineArrays применяет операцию к двум и более исходным массивам. Для всех операций, кроме concat,
+// исходные массивы должны быть отсортированы: каждый шаг - линейный проход по двум отсортированным массивам
+func combineArrays(operation string, sorted [][]int) []int {
+	result := sorted[0]
+	switch operation {
+	case combineConcat:
+		result = slices.Concat(sorted...)
+	case combineMerge, combineUnion:
+		for _, next := range sorted[1:] {
+			result = mergeSorted(result, next)
+		}
+		if operation == combineUnion {
+			result = slices.Compact(result)
+		}
+	case combineIntersect:
+		for _, next := range sorted[1:] {
+			result = intersectSorted(result, next)
+		}
+	case combineDifference:
+		for _, next := range sorted[1:] {
+			result = differenceSorted(result, next)
+		}
+	}
+	return result
+}
+
+// combineArraysHandler - POST /arrays/combine: объединяет сохраненные массивы и сохраняет результат
+// новым массивом. Неотсортированные исходные массивы сортируются алгоритмом из реестра (сами они не меняются)
+func combineArraysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CombineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	if !slices.Contains(combineOperations, req.Operation) {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Неизвестная операция %q, доступны: %s", req.Operation, strings.Join(combineOperations, ", ")),
+		}, http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) < 2 || len(req.IDs) > maxCombineArrays {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Укажите от 2 до %d ID массивов (ids)", maxCombineArrays),
+		}, http.StatusBadRequest)
+		return
+	}
+	policy, err := duplicatesPolicy(r)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+	if req.Algorithm == "" {
+		req.Algorithm = combineSortAlgorithm
+	}
+	algorithm, alg, ok := lookupSortAlgorithm(req.Algorithm)
+	if !ok {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Неизвестный алгоритм сортировки %q, доступны: %s", algorithm, strings.Join(sortAlgorithmNames(), ", ")),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	u := currentUser(r.Context())
+	sources := make([][]int, len(req.IDs))
+	total := 0
+	for i, id := range req.IDs {
+		numbers, err := getArrayByID(r.Context(), u, id)
+		if err != nil {
+			writeLoadError(w, fmt.Errorf("массив %d: %w", id, err))
+			return
+		}
+		sources[i] = numbers
+		total += len(numbers)
+	}
+
+	// Результат слияния и конкатенации содержит все элементы исходных массивов: длину можно проверить до сортировки
+	if req.Operation == combineMerge || req.Operation == combineConcat {
+		if !checkArrayLength(w, total, appConfig.MaxArrayLength, "(RPS_MAX_ARRAY_LENGTH)") {
+			return
+		}
+	}
+
+	if req.Operation != combineConcat {
+		reason := fmt.Sprintf("для алгоритма %s, используйте merge, quick или heap", algorithm)
+		for i, numbers := range sources {
+			if isSortedAsc(numbers) {
+				continue
+			}
+			if !checkArrayLength(w, len(numbers), alg.maxLength, reason) {
+				return
+			}
+			sources[i] = alg.sort(numbers)
+		}
+	}
+
+	_, span := startSpan(r.Context(), "combineArrays",
+		attribute.String("combine.operation", req.Operation),
+		attribute.Int("combine.arrays", len(sources)))
+	result := combineArrays(req.Operation, sources)
+	span.End()
+
+	if len(result) == 0 {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Результат операции - пустой массив, он не сохранен",
+		}, http.StatusUnprocessableEntity)
+		return
+	}
+	// Результат сохраняется новым массивом, поэтому на него действует то же ограничение длины
+	if !checkArrayLength(w, len(result), appConfig.MaxArrayLength, "(RPS_MAX_ARRAY_LENGTH)") {
+		return
+	}
+
+	id, existing, err := createArray(r.Context(), u.ID, result, policy, auditEntry{
+		Action: auditSave,
+		Before: map[string]interface{}{"operation": req.Operation, "sources": req.IDs},
+	})
+	if err != nil {
+		writeCreateError(w, err)
+		return
+	}
+
+	arrayOperationsTotal.inc("combine")
+
+	resp := Response{
+		Success: true,
+		Message: fmt.Sprintf("Результат сохранен. Новый ID: %d", id),
+		Data: map[string]interface{}{
+			"id":        id,
+			"array":     result,
+			"operation": req.Operation,
+			"sources":   req.IDs,
+		},
+	}
+	jsonResponse(w, resp, createdStatus(&resp, id, existing))
+}
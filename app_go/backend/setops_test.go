@@ -0,0 +1,50 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSortedSetOperations(t *testing.T) {
+	cases := []struct {
+		a, b               []int
+		merge, inter, diff []int
+	}{
+		{nil, nil, []int{}, nil, nil},
+		{[]int{1, 2}, nil, []int{1, 2}, nil, []int{1, 2}},
+		{nil, []int{1, 2}, []int{1, 2}, nil, nil},
+		{[]int{1, 1, 3}, []int{1, 2, 3, 3}, []int{1, 1, 1, 2, 3, 3, 3}, []int{1, 3}, nil},
+		{[]int{-5, 0, 0, 7}, []int{0, 8}, []int{-5, 0, 0, 0, 7, 8}, []int{0}, []int{-5, 7}},
+		{[]int{1, 2, 3}, []int{4, 5}, []int{1, 2, 3, 4, 5}, nil, []int{1, 2, 3}},
+	}
+	for _, c := range cases {
+		if got := mergeSorted(c.a, c.b); !slices.Equal(got, c.merge) {
+			t.Errorf("mergeSorted(%v, %v) = %v, ожидалось %v", c.a, c.b, got, c.merge)
+		}
+		if got := intersectSorted(c.a, c.b); !slices.Equal(got, c.inter) {
+			t.Errorf("intersectSorted(%v, %v) = %v, ожидалось %v", c.a, c.b, got, c.inter)
+		}
+		if got := differenceSorted(c.a, c.b); !slices.Equal(got, c.diff) {
+			t.Errorf("differenceSorted(%v, %v) = %v, ожидалось %v", c.a, c.b, got, c.diff)
+		}
+	}
+}
+
+func TestCombineArrays(t *testing.T) {
+	sources := [][]int{{1, 2, 2, 5}, {2, 3, 5}, {2, 5, 9}}
+	cases := []struct {
+		operation string
+		want      []int
+	}{
+		{combineMerge, []int{1, 2, 2, 2, 2, 3, 5, 5, 5, 9}},
+		{combineUnion, []int{1, 2, 3, 5, 9}},
+		{combineIntersect, []int{2, 5}},
+		{combineDifference, []int{1}},
+		{combineConcat, []int{1, 2, 2, 5, 2, 3, 5, 2, 5, 9}},
+	}
+	for _, c := range cases {
+		if got := combineArrays(c.operation, sources); !slices.Equal(got, c.want) {
+			t.Errorf("%s = %v, ожидалось %v", c.operation, got, c.want)
+		}
+	}
+}
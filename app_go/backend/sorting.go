@@ -0,0 +1,184 @@
+package main
+
+import "sort"
+
+// sortFunc сортирует срез по возрастанию на месте и возвращает его
+type sortFunc func(arr []int) []int
+
+// defaultSortAlgorithm используется, если алгоритм не указан в запросе
+const defaultSortAlgorithm = "selection"
+
+// quadraticSortMaxLength - предел длины для алгоритмов O(n^2): 20000 элементов сортируются за доли секунды
+const quadraticSortMaxLength = 20000
+
+// sortAlgorithm - алгоритм из реестра
+type sortAlgorithm struct {
+	sort      sortFunc
+	maxLength int // наибольшая допустимая длина массива; 0 - только общий предел RPS_MAX_ARRAY_LENGTH
+}
+
+// sortAlgorithms - реестр доступных алгоритмов сортировки по имени
+var sortAlgorithms = map[string]sortAlgorithm{
+	"selection": {sort: selectionSort, maxLength: quadraticSortMaxLength},
+	"insertion": {sort: insertionSort, maxLength: quadraticSortMaxLength},
+	"merge":     {sort: mergeSort},
+	"quick":     {sort: quickSort},
+	"heap":      {sort: heapSort},
+}
+
+// lookupSortAlgorithm возвращает алгоритм по имени; пустое имя - алгоритм по умолчанию
+func lookupSortAlgorithm(name string) (string, sortAlgorithm, bool) {
+	if name == "" {
+		name = defaultSortAlgorithm
+	}
+	alg, ok := sortAlgorithms[name]
+	return name, alg, ok
+}
+
+// sortAlgorithmNames возвращает имена алгоритмов в алфавитном порядке
+func sortAlgorithmNames() []string {
+	names := make([]string, 0, len(sortAlgorithms))
+	for name := range sortAlgorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func selectionSort(arr []int) []int {
+	n := len(arr)
+	for i := 0; i < n-1; i++ {
+		minIndex := i
+		for j := i + 1; j < n; j++ {
+			if arr[j] < arr[minIndex] {
+				minIndex = j
+			}
+		}
+		if minIndex != i {
+			arr[i], arr[minIndex] = arr[minIndex], arr[i]
+		}
+	}
+	return arr
+}
+
+func insertionSort(arr []int) []int {
+	for i := 1; i < len(arr); i++ {
+		key := arr[i]
+		j := i - 1
+		for j >= 0 && arr[j] > key {
+			arr[j+1] = arr[j]
+			j--
+		}
+		arr[j+1] = key
+	}
+	return arr
+}
+
+func mergeSort(arr []int) []int {
+	if len(arr) < 2 {
+		return arr
+	}
+	buf := make([]int, len(arr))
+	mergeSortRange(arr, buf, 0, len(arr))
+	return arr
+}
+
+// mergeSortRange сортирует arr[lo:hi], используя buf как временный буфер
+func mergeSortRange(arr, buf []int, lo, hi int) {
+	if hi-lo < 2 {
+		return
+	}
+	mid := (lo + hi) / 2
+	mergeSortRange(arr, buf, lo, mid)
+	mergeSortRange(arr, buf, mid, hi)
+
+	i, j, k := lo, mid, lo
+	for i < mid && j < hi {
+		if arr[i] <= arr[j] {
+			buf[k] = arr[i]
+			i++
+		} else {
+			buf[k] = arr[j]
+			j++
+		}
+		k++
+	}
+	k += copy(buf[k:], arr[i:mid])
+	copy(buf[k:], arr[j:hi])
+	copy(arr[lo:hi], buf[lo:hi])
+}
+
+func quickSort(arr []int) []int {
+	quickSortRange(arr, 0, len(arr)-1)
+	return arr
+}
+
+// quickSortRange сортирует arr[lo..hi]; рекурсия идет в меньшую часть,
+// чтобы глубина стека не превышала O(log n)
+func quickSortRange(arr []int, lo, hi int) {
+	for lo < hi {
+		lt, gt := partition(arr, lo, hi)
+		if lt-lo < hi-gt {
+			quickSortRange(arr, lo, lt-1)
+			lo = gt + 1
+		} else {
+			quickSortRange(arr, gt+1, hi)
+			hi = lt - 1
+		}
+	}
+}
+
+// partition - разбиение на три части (голландский флаг) с опорным элементом-медианой трех:
+// arr[lo..lt-1] < pivot, arr[lt..gt] == pivot, arr[gt+1..hi] > pivot. Равные опорному элементы
+// больше не участвуют в сортировке, поэтому массив из повторов сортируется за линейное время
+func partition(arr []int, lo, hi int) (lt, gt int) {
+	mid := lo + (hi-lo)/2
+	pivot := max(min(arr[lo], arr[mid]), min(max(arr[lo], arr[mid]), arr[hi]))
+
+	lt, i, gt := lo, lo, hi
+	for i <= gt {
+		switch {
+		case arr[i] < pivot:
+			arr[lt], arr[i] = arr[i], arr[lt]
+			lt++
+			i++
+		case arr[i] > pivot:
+			arr[i], arr[gt] = arr[gt], arr[i]
+			gt--
+		default:
+			i++
+		}
+	}
+	return lt, gt
+}
+
+func heapSort(arr []int) []int {
+	n := len(arr)
+	for i := n/2 - 1; i >= 0; i-- {
+		siftDown(arr, i, n)
+	}
+	for end := n - 1; end > 0; end-- {
+		arr[0], arr[end] = arr[end], arr[0]
+		siftDown(arr, 0, end)
+	}
+	return arr
+}
+
+// siftDown восстанавливает свойство max-кучи для arr[:n], начиная с узла i
+func siftDown(arr []int, i, n int) {
+	for {
+		largest := i
+		left, right := 2*i+1, 2*i+2
+		if left < n && arr[left] > arr[largest] {
+			largest = left
+		}
+		if right < n && arr[right] > arr[largest] {
+			largest = right
+		}
+		if largest == i {
+			return
+		}
+		arr[i], arr[largest] = arr[largest], arr[i]
+		i = largest
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestSortAlgorithms(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	inputs := [][]int{{}, {1}, {2, 1}, {5, 5, 5, 5}, {3, -1, 2, -1, 0, 3}}
+	for range 20 {
+		a := make([]int, rng.IntN(300))
+		for i := range a {
+			a[i] = rng.IntN(20) - 10
+		}
+		inputs = append(inputs, a)
+	}
+
+	for _, name := range sortAlgorithmNames() {
+		_, alg, _ := lookupSortAlgorithm(name)
+		for _, in := range inputs {
+			want := slices.Clone(in)
+			slices.Sort(want)
+			if got := alg.sort(slices.Clone(in)); !slices.Equal(got, want) {
+				t.Fatalf("%s(%v) = %v, ожидалось %v", name, in, got, want)
+			}
+		}
+	}
+}
+
+// Разбиение на три части: массив из одинаковых элементов сортируется за линейное время,
+// а не за O(n^2), как при разбиении Ломуто
+func TestQuickSortAllEqual(t *testing.T) {
+	a := make([]int, 100000)
+	for i := range a {
+		a[i] = 7
+	}
+	if got := quickSort(a); len(got) != 100000 || !slices.IsSorted(got) {
+		t.Fatal("массив из одинаковых элементов отсортирован неверно")
+	}
+}
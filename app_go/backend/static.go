@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"RPS/app_go/frontend"
+)
+
+// staticFile - содержимое файла интерфейса и хеш этого содержимого
+type staticFile struct {
+	data    []byte
+	hash    string
+	modTime time.Time
+}
+
+// staticAssets раздает файлы интерфейса из встроенной ФС или из каталога на диске
+type staticAssets struct {
+	fsys   fs.FS
+	reload bool // перечитывать файлы при каждом запросе (режим разработки)
+	files  map[string]*staticFile
+	index  *staticFile // index.html со ссылками, содержащими хеши файлов
+}
+
+// newStaticAssets загружает встроенные файлы, либо, если dir не пуст,
+// раздает файлы из каталога dir без кэширования
+func newStaticAssets(dir string) (*staticAssets, error) {
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+		return &staticAssets{fsys: os.DirFS(dir), reload: true}, nil
+	}
+
+	a := &staticAssets{fsys: frontend.Files}
+	files, index, err := a.load()
+	if err != nil {
+		return nil, err
+	}
+	a.files, a.index = files, index
+	return a, nil
+}
+
+// load читает все файлы, считает их хеши и собирает index.html
+func (a *staticAssets) load() (map[string]*staticFile, *staticFile, error) {
+	files := make(map[string]*staticFile)
+	err := fs.WalkDir(a.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isStaticAsset(name) {
+			return err
+		}
+		data, err := fs.ReadFile(a.fsys, name)
+		if err != nil {
+			return err
+		}
+		modTime := time.Time{}
+		if info, err := d.Info(); err == nil {
+			modTime = info.ModTime()
+		}
+		files[name] = newStaticFile(data, modTime)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	page, ok := files["index.html"]
+	if !ok {
+		return nil, nil, fs.ErrNotExist
+	}
+
+	// Добавляем к ссылкам на файлы параметр ?v=<хеш>, чтобы браузер
+	// мог кэшировать их бессрочно и получал новую версию после изменения
+	html := string(page.data)
+	for name, f := range files {
+		html = strings.ReplaceAll(html, `"/static/`+name+`"`, `"/static/`+name+`?v=`+f.hash+`"`)
+	}
+
+	return files, newStaticFile([]byte(html), page.modTime), nil
+}
+
+func newStaticFile(data []byte, modTime time.Time) *staticFile {
+	sum := sha256.Sum256(data)
+	return &staticFile{data: data, hash: hex.EncodeToString(sum[:8]), modTime: modTime}
+}
+
+// isStaticAsset отсекает файлы, не относящиеся к интерфейсу (например, embed.go в режиме разработки)
+func isStaticAsset(name string) bool {
+	return !strings.HasSuffix(name, ".go")
+}
+
+// current возвращает актуальный набор файлов
+func (a *staticAssets) current() (map[string]*staticFile, *staticFile, error) {
+	if a.reload {
+		return a.load()
+	}
+	return a.files, a.index, nil
+}
+
+// serveStatic обрабатывает запросы /static/...
+func (a *staticAssets) serveStatic(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/static/")
+	if !fs.ValidPath(name) || !isStaticAsset(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	files, _, err := a.current()
+	if err != nil {
+		http.Error(w, "Ошибка чтения файлов интерфейса", http.StatusInternalServerError)
+		return
+	}
+	f, ok := files[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Ссылка с актуальным хешем не изменится никогда - кэшируем надолго,
+	// иначе браузер должен перепроверять файл по ETag
+	if !a.reload && r.URL.Query().Get("v") == f.hash {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	a.serveFile(w, r, name, f)
+}
+
+// serveIndex отдает главную страницу приложения
+func (a *staticAssets) serveIndex(w http.ResponseWriter, r *http.Request) {
+	_, index, err := a.current()
+	if err != nil {
+		http.Error(w, "Ошибка чтения файлов интерфейса", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	a.serveFile(w, r, "index.html", index)
+}
+
+// serveFile отдает файл с ETag; ServeContent сам отвечает 304 на If-None-Match
+func (a *staticAssets) serveFile(w http.ResponseWriter, r *http.Request, name string, f *staticFile) {
+	w.Header().Set("ETag", `"`+f.hash+`"`)
+	http.ServeContent(w, r, name, f.modTime, bytes.NewReader(f.data))
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultHistogramBins = 10
+	maxHistogramBins     = 100
+)
+
+var defaultPercentiles = []float64{5, 25, 50, 75, 95}
+
+// arraySummary - сводка, которая хранится в столбцах arrays и обновляется при каждой записи массива
+type arraySummary struct {
+	Length int
+	Sum    string // DECIMAL: сумма может не поместиться в int64
+	Min    int
+	Max    int
+	Mean   float64
+}
+
+// summarize вычисляет сводку непустого массива
+func summarize(numbers []int) arraySummary {
+	sum := new(big.Int)
+	s := arraySummary{Length: len(numbers), Min: numbers[0], Max: numbers[0]}
+	for _, v := range numbers {
+		sum.Add(sum, big.NewInt(int64(v)))
+		s.Min = min(s.Min, v)
+		s.Max = max(s.Max, v)
+	}
+	s.Sum = sum.String()
+	mean, _ := new(big.Float).Quo(new(big.Float).SetInt(sum), big.NewFloat(float64(len(numbers)))).Float64()
+	s.Mean = mean
+	return s
+}
+
+// histogramBin - интервал гистограммы [From, To); последний интервал включает To
+type histogramBin struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+// percentileValue - значение перцентиля P (0..100)
+type percentileValue struct {
+	P     float64 `json:"p"`
+	Value float64 `json:"value"`
+}
+
+// ArrayStats - статистика массива
+type ArrayStats struct {
+	Length      int               `json:"length"`
+	Sum         json.Number       `json:"sum"`
+	Min         int               `json:"min"`
+	Max         int               `json:"max"`
+	Mean        float64           `json:"mean"`
+	Median      float64           `json:"median"`
+	Mode        []int             `json:"mode"` // все значения с наибольшей частотой, по возрастанию
+	ModeCount   int               `json:"mode_count"`
+	Variance    float64           `json:"variance"` // дисперсия генеральной совокупности
+	StdDev      float64           `json:"std_dev"`
+	Percentiles []percentileValue `json:"percentiles"`
+	Histogram   []histogramBin    `json:"histogram"`
+}
+
+// computeStats вычисляет статистику непустого массива. Перцентили - линейная интерполяция
+// между соседними элементами отсортированного массива, гистограмма - bins равных интервалов от min до max
+func computeStats(numbers []int, percentiles []float64, bins int) ArrayStats {
+	summary := summarize(numbers)
+	st := ArrayStats{
+		Length: summary.Length,
+		Sum:    json.Number(summary.Sum),
+		Min:    summary.Min,
+		Max:    summary.Max,
+		Mean:   summary.Mean,
+	}
+
+	sorted := slices.Clone(numbers)
+	slices.Sort(sorted)
+	st.Median = percentile(sorted, 50)
+	for _, p := range percentiles {
+		st.Percentiles = append(st.Percentiles, percentileValue{P: p, Value: percentile(sorted, p)})
+	}
+
+	// Дисперсия по отклонениям от среднего: сумма квадратов самих значений теряет точность
+	var squares float64
+	for _, v := range sorted {
+		d := float64(v) - st.Mean
+		squares += d * d
+	}
+	st.Variance = squares / float64(len(sorted))
+	st.StdDev = math.Sqrt(st.Variance)
+
+	// В отсортированном массиве равные значения идут подряд
+	for i := 0; i < len(sorted); {
+		j := i
+		for j < len(sorted) && sorted[j] == sorted[i] {
+			j++
+		}
+		switch {
+		case j-i > st.ModeCount:
+			st.Mode, st.ModeCount = []int{sorted[i]}, j-i
+		case j-i == st.ModeCount:
+			st.Mode = append(st.Mode, sorted[i])
+		}
+		i = j
+	}
+
+	st.Histogram = valueHistogram(sorted, bins)
+	return st
+}
+
+// percentile - значение перцентиля p отсортированного массива
+func percentile(sorted []int, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := min(lo+1, len(sorted)-1)
+	return float64(sorted[lo]) + (rank-float64(lo))*(float64(sorted[hi])-float64(sorted[lo]))
+}
+
+// valueHistogram делит [min, max] отсортированного массива на bins равных интервалов
+func valueHistogram(sorted []int, bins int) []histogramBin {
+	lo, hi := float64(sorted[0]), float64(sorted[len(sorted)-1])
+	if lo == hi {
+		return []histogramBin{{From: lo, To: hi, Count: len(sorted)}}
+	}
+	width := (hi - lo) / float64(bins)
+	result := make([]histogramBin, bins)
+	for i := range result {
+		result[i].From = lo + float64(i)*width
+		result[i].To = lo + float64(i+1)*width
+	}
+	result[bins-1].To = hi
+	for _, v := range sorted {
+		i := min(int((float64(v)-lo)/width), bins-1)
+		result[i].Count++
+	}
+	return result
+}
+
+// backfillArraySummaries заполняет сводку, хэши и упорядоченность строк, записанных до появления этих столбцов,
+// и заодно исправляет их is_sorted. Строки с поврежденными данными остаются без сводки
+func backfillArraySummaries(ctx context.Context) (int, error) {
+	query := "SELECT id, array_data FROM arrays WHERE length IS NULL OR content_hash IS NULL OR sort_order IS NULL"
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, storeError(ctx, "backfillArraySummaries", err)
+	}
+	pending := map[int]string{}
+	for rows.Next() {
+		var id int
+		var arrayData string
+		if err := rows.Scan(&id, &arrayData); err != nil {
+			rows.Close()
+			return 0, storeError(ctx, "backfillArraySummaries", err)
+		}
+		pending[id] = arrayData
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, storeError(ctx, "backfillArraySummaries", err)
+	}
+
+	updated := 0
+	for id, arrayData := range pending {
+		numbers, err := parseArrayString(arrayData)
+		if err != nil {
+			continue
+		}
+		args := append(summaryColumns(numbers), isSortedAsc(numbers), id)
+		_, err = db.ExecContext(ctx, `
+			UPDATE arrays SET length = ?, sum = ?, min_value = ?, max_value = ?, mean = ?,
+				content_hash = ?, elements_hash = ?, sort_order = ?, inversions = ?, nearly_sorted = ?, is_sorted = ?
+			WHERE id = ?`, args...)
+		if err != nil {
+			return updated, storeError(ctx, "backfillArraySummaries", err)
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// statsHandler - статистика массива: GET /arrays/stats?id=1&bins=10&percentiles=5,25,50,75,95
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Неверный ID массива",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	percentiles, bins, err := parseStatsParams(r)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	numbers, err := getArrayByID(r.Context(), currentUser(r.Context()), id)
+	if err != nil {
+		writeLoadError(w, err)
+		return
+	}
+
+	_, span := startSpan(r.Context(), "computeStats", attribute.Int("array.length", len(numbers)))
+	stats := computeStats(numbers, percentiles, bins)
+	span.End()
+
+	jsonResponse(w, Response{
+		Success: true,
+		Data:    stats,
+	}, http.StatusOK)
+}
+
+func parseStatsParams(r *http.Request) ([]float64, int, error) {
+	q := r.URL.Query()
+	bins := defaultHistogramBins
+	if v := q.Get("bins"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxHistogramBins {
+			return nil, 0, fmt.Errorf("bins должен быть от 1 до %d", maxHistogramBins)
+		}
+		bins = n
+	}
+
+	percentiles := defaultPercentiles
+	if v := q.Get("percentiles"); v != "" {
+		percentiles = nil
+		for _, item := range strings.Split(v, ",") {
+			p, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+			if err != nil || math.IsNaN(p) || p < 0 || p > 100 {
+				return nil, 0, fmt.Errorf("неверный перцентиль %q: нужно число от 0 до 100", item)
+			}
+			percentiles = append(percentiles, p)
+		}
+	}
+	return percentiles, bins, nil
+}
+
+// summaryColumns - значения вычисляемых столбцов для INSERT/UPDATE в порядке
+// length, sum, min_value, max_value, mean, content_hash, elements_hash, sort_order, inversions, nearly_sorted
+func summaryColumns(numbers []int) []interface{} {
+	s := summarize(numbers)
+	order := analyzeOrder(numbers)
+	return []interface{}{s.Length, s.Sum, s.Min, s.Max, s.Mean, contentHash(numbers), elementsHash(numbers),
+		order.Order, order.Inversions, order.NearlySorted}
+}
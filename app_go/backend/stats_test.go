@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestPercentile(t *testing.T) {
+	cases := []struct {
+		sorted []int
+		p      float64
+		want   float64
+	}{
+		{[]int{5}, 0, 5},
+		{[]int{5}, 50, 5},
+		{[]int{5}, 100, 5},
+		{[]int{1, 2, 3, 4}, 0, 1},
+		{[]int{1, 2, 3, 4}, 50, 2.5},
+		{[]int{1, 2, 3, 4}, 100, 4},
+		{[]int{1, 2, 3, 4}, 25, 1.75},
+		{[]int{-10, 0, 10}, 75, 5},
+		{[]int{math.MinInt, math.MaxInt}, 0, math.MinInt},
+	}
+	for _, c := range cases {
+		if got := percentile(c.sorted, c.p); got != c.want {
+			t.Errorf("percentile(%v, %v) = %v, ожидалось %v", c.sorted, c.p, got, c.want)
+		}
+	}
+}
+
+func TestValueHistogram(t *testing.T) {
+	cases := []struct {
+		sorted []int
+		bins   int
+		want   []histogramBin
+	}{
+		{[]int{3, 3, 3}, 5, []histogramBin{{From: 3, To: 3, Count: 3}}},
+		{[]int{0, 10}, 1, []histogramBin{{From: 0, To: 10, Count: 2}}},
+		{[]int{0, 1, 4, 5, 9, 10}, 2, []histogramBin{{From: 0, To: 5, Count: 3}, {From: 5, To: 10, Count: 3}}},
+		{[]int{0, 3, 10}, 4, []histogramBin{{From: 0, To: 2.5, Count: 1}, {From: 2.5, To: 5, Count: 1},
+			{From: 5, To: 7.5}, {From: 7.5, To: 10, Count: 1}}},
+	}
+	for _, c := range cases {
+		if got := valueHistogram(c.sorted, c.bins); !slices.Equal(got, c.want) {
+			t.Errorf("valueHistogram(%v, %d) = %v, ожидалось %v", c.sorted, c.bins, got, c.want)
+		}
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	st := computeStats([]int{4, 1, 2, 2, 1}, []float64{50}, 3)
+	if st.Sum != "10" || st.Min != 1 || st.Max != 4 || st.Mean != 2 || st.Median != 2 {
+		t.Fatalf("сводка %+v", st)
+	}
+	if !slices.Equal(st.Mode, []int{1, 2}) || st.ModeCount != 2 {
+		t.Fatalf("мода %v (%d), ожидалось [1 2] (2)", st.Mode, st.ModeCount)
+	}
+	if st.Variance != 1.2 {
+		t.Fatalf("дисперсия %v, ожидалось 1.2", st.Variance)
+	}
+}
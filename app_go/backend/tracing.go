@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer создает спаны сервера; до вызова setupTracing спаны ничего не записывают
+var tracer = otel.Tracer("RPS/app_go/backend")
+
+// setupTracing настраивает OpenTelemetry. Трассировка ведется всегда, чтобы у каждого
+// запроса был trace ID; экспорт спанов задается cfg.TraceExporter:
+//   - ""/"none" - спаны никуда не выгружаются
+//   - "stdout"  - JSON в стандартный вывод
+//   - "file"    - JSON в файл cfg.TraceFile
+//   - "otlp"    - OTLP/HTTP в коллектор cfg.OTLPEndpoint (например http://localhost:4318)
+//
+// Возвращает функцию, которая выгружает оставшиеся спаны и закрывает экспортер
+func setupTracing(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName("rps-backend"),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания ресурса трассировки: %v", err)
+	}
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	var closeOutput io.Closer
+
+	switch strings.ToLower(cfg.TraceExporter) {
+	case "", "none":
+	case "stdout":
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("ошибка создания экспортера трассировки: %v", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	case "file":
+		f, err := os.OpenFile(cfg.TraceFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка открытия файла трассировки: %v", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("ошибка создания экспортера трассировки: %v", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+		closeOutput = f
+	case "otlp":
+		exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+		if err != nil {
+			return nil, fmt.Errorf("ошибка создания OTLP-экспортера: %v", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	default:
+		return nil, fmt.Errorf("неизвестный экспортер трассировки %q: ожидается none, stdout, file или otlp", cfg.TraceExporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeOutput != nil {
+			if cerr := closeOutput.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}
+
+// traceRequests открывает спан на каждый HTTP-запрос, продолжая трассу из заголовка
+// traceparent, и возвращает ее ID клиенту в заголовке X-Trace-ID
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			))
+		defer span.End()
+
+		if id := requestID(ctx); id != "" {
+			span.SetAttributes(attribute.String("request.id", id))
+		}
+		if sc := span.SpanContext(); sc.HasTraceID() {
+			w.Header().Set("X-Trace-ID", sc.TraceID().String())
+		}
+
+		r = r.WithContext(ctx)
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		// Шаблон маршрута известен только после того, как ServeMux выбрал обработчик
+		if route := requestRoute(ctx); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// startSpan открывает дочерний спан для этапа обработки запроса
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// startDBSpan открывает спан для обращения к MySQL
+func startDBSpan(ctx context.Context, op, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "db "+op, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameMySQL,
+			semconv.DBOperationName(op),
+			semconv.DBQueryText(strings.Join(strings.Fields(query), " ")),
+		))
+}
+
+// spanError отмечает текущий спан как завершившийся ошибкой
+func spanError(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// traceID возвращает ID трассы из контекста или пустую строку
+func traceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Куда сохраняется результат преобразования
+const (
+	transformSaveVersion = "version" // новой версией исходного массива (по умолчанию)
+	transformSaveNew     = "new"     // новым массивом, исходный не меняется
+	transformSaveNone    = "none"    // не сохранять, только вернуть результат
+)
+
+// maxTransformOperations - наибольшее число операций в одном запросе
+const maxTransformOperations = 50
+
+// TransformRequest - операции, которые применяются к сохраненному массиву по порядку
+type TransformRequest struct {
+	Operations []TransformOperation `json:"operations"`
+	Save       string               `json:"save,omitempty"`
+}
+
+// TransformOperation - шаг преобразования:
+//   - unique: убрать повторы, оставив первое вхождение
+//   - reverse: обратный порядок
+//   - shuffle: перемешать; seed делает результат воспроизводимым (без seed выбирается случайный и возвращается в ответе)
+//   - sort: отсортировать алгоритмом algorithm из реестра
+//   - filter: оставить элементы, для которых выполняется predicate (gt, gte, lt, lte, eq, ne со значением value;
+//     between с min и max; even; odd)
+//   - clamp: ограничить значения диапазоном [min, max]
+//   - scale: умножить на factor и прибавить offset
+//   - take, skip: оставить первые count элементов или пропустить их
+type TransformOperation struct {
+	Op        string  `json:"op"`
+	Seed      *uint64 `json:"seed,omitempty"`
+	Algorithm string  `json:"algorithm,omitempty"`
+	Predicate string  `json:"predicate,omitempty"`
+	Value     *int    `json:"value,omitempty"`
+	Min       *int    `json:"min,omitempty"`
+	Max       *int    `json:"max,omitempty"`
+	Factor    *int    `json:"factor,omitempty"`
+	Offset    int     `json:"offset,omitempty"`
+	Count     *int    `json:"count,omitempty"`
+}
+
+// applyPipeline применяет операции к копии numbers. Для shuffle без seed в ops записывается
+// выбранный seed, чтобы результат можно было повторить
+func applyPipeline(numbers []int, ops []TransformOperation) ([]int, error) {
+	result := slices.Clone(numbers)
+	for i := range ops {
+		op := &ops[i]
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%w: операция %d (%s): %s", errInvalidEdit, i+1, op.Op, fmt.Sprintf(format, args...))
+		}
+		require := func(names string, values ...*int) error {
+			for _, v := range values {
+				if v == nil {
+					return fail("нужны параметры %s", names)
+				}
+			}
+			return nil
+		}
+
+		switch op.Op {
+		case "unique":
+			seen := make(map[int]bool, len(result))
+			unique := result[:0]
+			for _, v := range result {
+				if !seen[v] {
+					seen[v] = true
+					unique = append(unique, v)
+				}
+			}
+			result = unique
+		case "reverse":
+			slices.Reverse(result)
+		case "shuffle":
+			if op.Seed == nil {
+				seed := rand.Uint64()
+				op.Seed = &seed
+			}
+			rng := rand.New(rand.NewPCG(*op.Seed, 0))
+			rng.Shuffle(len(result), func(i, j int) { result[i], result[j] = result[j], result[i] })
+		case "sort":
+			name, alg, ok := lookupSortAlgorithm(op.Algorithm)
+			if !ok {
+				return nil, fail("неизвестный алгоритм %q, доступны: %s", name, strings.Join(sortAlgorithmNames(), ", "))
+			}
+			if alg.maxLength > 0 && len(result) > alg.maxLength {
+				return nil, fail("массив из %d элементов слишком велик для алгоритма %s", len(result), name)
+			}
+			op.Algorithm = name
+			result = alg.sort(result)
+		case "filter":
+			keep, err := filterPredicate(op)
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			result = slices.DeleteFunc(result, func(v int) bool { return !keep(v) })
+		case "clamp":
+			if err := require("min и max", op.Min, op.Max); err != nil {
+				return nil, err
+			}
+			if *op.Min > *op.Max {
+				return nil, fail("min больше max")
+			}
+			for j, v := range result {
+				result[j] = min(max(v, *op.Min), *op.Max)
+			}
+		case "scale":
+			if err := require("factor", op.Factor); err != nil {
+				return nil, err
+			}
+			for j, v := range result {
+				scaled, ok := scaleInt(v, *op.Factor, op.Offset)
+				if !ok {
+					return nil, fail("переполнение для элемента %d", v)
+				}
+				result[j] = scaled
+			}
+		case "take", "skip":
+			if err := require("count", op.Count); err != nil {
+				return nil, err
+			}
+			if *op.Count < 0 {
+				return nil, fail("count не может быть отрицательным")
+			}
+			n := min(*op.Count, len(result))
+			if op.Op == "take" {
+				result = result[:n]
+			} else {
+				result = result[n:]
+			}
+		default:
+			return nil, fmt.Errorf("%w: операция %d: неизвестная операция %q, доступны: unique, reverse, shuffle, sort, filter, clamp, scale, take, skip",
+				errInvalidEdit, i+1, op.Op)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%w: после преобразования массив пуст", errInvalidEdit)
+	}
+	return result, nil
+}
+
+// filterPredicate возвращает условие операции filter
+func filterPredicate(op *TransformOperation) (func(int) bool, error) {
+	compare := map[string]func(a, b int) bool{
+		"gt":  func(a, b int) bool { return a > b },
+		"gte": func(a, b int) bool { return a >= b },
+		"lt":  func(a, b int) bool { return a < b },
+		"lte": func(a, b int) bool { return a <= b },
+		"eq":  func(a, b int) bool { return a == b },
+		"ne":  func(a, b int) bool { return a != b },
+	}
+	switch op.Predicate {
+	case "even":
+		return func(v int) bool { return v%2 == 0 }, nil
+	case "odd":
+		return func(v int) bool { return v%2 != 0 }, nil
+	case "between":
+		if op.Min == nil || op.Max == nil {
+			return nil, errors.New("для between нужны min и max")
+		}
+		lo, hi := *op.Min, *op.Max
+		return func(v int) bool { return v >= lo && v <= hi }, nil
+	}
+	cmp, ok := compare[op.Predicate]
+	if !ok {
+		return nil, fmt.Errorf("неизвестное условие %q, доступны: gt, gte, lt, lte, eq, ne, between, even, odd", op.Predicate)
+	}
+	if op.Value == nil {
+		return nil, fmt.Errorf("для %s нужно value", op.Predicate)
+	}
+	value := *op.Value
+	return func(v int) bool { return cmp(v, value) }, nil
+}
+
+// scaleInt вычисляет v*factor+offset; false - результат не помещается в int
+func scaleInt(v, factor, offset int) (int, bool) {
+	if v != 0 && factor != 0 {
+		if (v == -1 && factor == math.MinInt) || (factor == -1 && v == math.MinInt) {
+			return 0, false
+		}
+		if p := v * factor; p/factor != v {
+			return 0, false
+		}
+	}
+	p := v * factor
+	s := p + offset
+	if (offset > 0 && s < p) || (offset < 0 && s > p) {
+		return 0, false
+	}
+	return s, true
+}
+
+// transformArrayHandler - POST /arrays/transform?id=1: применяет операции TransformRequest к массиву
+// и сохраняет результат новой версией (save=version), новым массивом (save=new) или только возвращает его (save=none)
+func transformArrayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Неверный ID массива",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	var req TransformRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if len(req.Operations) == 0 || len(req.Operations) > maxTransformOperations {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Укажите от 1 до %d операций (operations)", maxTransformOperations),
+		}, http.StatusBadRequest)
+		return
+	}
+	if req.Save == "" {
+		req.Save = transformSaveVersion
+	}
+	policy, err := duplicatesPolicy(r)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	u := currentUser(r.Context())
+	data := map[string]interface{}{"source": id, "operations": req.Operations, "save": req.Save}
+	var result []int
+	switch req.Save {
+	case transformSaveVersion:
+		var version int
+		version, result, err = editArray(r.Context(), u, id, versionChange{Action: versionTransform},
+			func(current arraySnapshot) ([]int, error) {
+				if current.Array == nil {
+					return nil, errInvalidArrayData
+				}
+				return applyPipeline(current.Array, req.Operations)
+			})
+		data["id"], data["version"] = id, version
+	case transformSaveNew, transformSaveNone:
+		var numbers []int
+		if numbers, err = getArrayByID(r.Context(), u, id); err == nil {
+			result, err = applyPipeline(numbers, req.Operations)
+		}
+	default:
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Неизвестное значение save %q, доступны: version, new, none", req.Save),
+		}, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeEditError(w, err)
+		return
+	}
+
+	resp := Response{
+		Success: true,
+		Message: "Массив преобразован",
+		Data:    data,
+	}
+	status := http.StatusOK
+	if req.Save == transformSaveNew {
+		newID, existing, err := createArray(r.Context(), u.ID, result, policy, auditEntry{
+			Action: auditSave,
+			Before: map[string]interface{}{"source": id, "operations": req.Operations},
+		})
+		if err != nil {
+			writeCreateError(w, err)
+			return
+		}
+		data["id"] = newID
+		status = createdStatus(&resp, newID, existing)
+	}
+
+	arrayOperationsTotal.inc("transform")
+
+	data["array"] = result
+	jsonResponse(w, resp, status)
+}
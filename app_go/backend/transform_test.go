@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestScaleInt(t *testing.T) {
+	cases := []struct {
+		v, factor, offset int
+		want              int
+		ok                bool
+	}{
+		{3, 2, 1, 7, true},
+		{0, math.MaxInt, 5, 5, true},
+		{math.MaxInt, 0, -1, -1, true},
+		{math.MaxInt, 1, 0, math.MaxInt, true},
+		{math.MaxInt, 1, 1, 0, false},
+		{math.MinInt, 1, -1, 0, false},
+		{math.MinInt, 1, 0, math.MinInt, true},
+		{math.MaxInt, -1, 0, -math.MaxInt, true},
+		{math.MaxInt, -1, -1, math.MinInt, true},
+		{math.MinInt, -1, 0, 0, false},
+		{-1, math.MinInt, 0, 0, false},
+		{math.MaxInt/2 + 1, 2, 0, 0, false},
+		{math.MinInt / 2, 2, 0, math.MinInt, true},
+		{math.MinInt/2 - 1, 2, 0, 0, false},
+	}
+	for _, c := range cases {
+		got, ok := scaleInt(c.v, c.factor, c.offset)
+		if ok != c.ok || (ok && got != c.want) {
+			t.Errorf("scaleInt(%d, %d, %d) = %d, %v, ожидалось %d, %v", c.v, c.factor, c.offset, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestApplyPipeline(t *testing.T) {
+	ptr := func(v int) *int { return &v }
+	seed := uint64(7)
+	cases := []struct {
+		name    string
+		ops     []TransformOperation
+		want    []int
+		invalid bool
+	}{
+		{"unique", []TransformOperation{{Op: "unique"}}, []int{3, 1, 2, 5}, false},
+		{"reverse", []TransformOperation{{Op: "reverse"}}, []int{5, 2, 1, 3, 1, 3}, false},
+		{"sort", []TransformOperation{{Op: "sort", Algorithm: "heap"}}, []int{1, 1, 2, 3, 3, 5}, false},
+		{"filter", []TransformOperation{{Op: "filter", Predicate: "gt", Value: ptr(2)}}, []int{3, 3, 5}, false},
+		{"between", []TransformOperation{{Op: "filter", Predicate: "between", Min: ptr(2), Max: ptr(3)}}, []int{3, 3, 2}, false},
+		{"clamp", []TransformOperation{{Op: "clamp", Min: ptr(2), Max: ptr(3)}}, []int{3, 2, 3, 2, 2, 3}, false},
+		{"scale", []TransformOperation{{Op: "scale", Factor: ptr(-2), Offset: 1}}, []int{-5, -1, -5, -1, -3, -9}, false},
+		{"take и skip", []TransformOperation{{Op: "skip", Count: ptr(1)}, {Op: "take", Count: ptr(2)}}, []int{1, 3}, false},
+		{"цепочка", []TransformOperation{{Op: "unique"}, {Op: "sort"}, {Op: "reverse"}}, []int{5, 3, 2, 1}, false},
+		{"shuffle c seed", []TransformOperation{{Op: "shuffle", Seed: &seed}, {Op: "sort"}}, []int{1, 1, 2, 3, 3, 5}, false},
+		{"переполнение scale", []TransformOperation{{Op: "scale", Factor: ptr(math.MaxInt)}}, nil, true},
+		{"пустой результат", []TransformOperation{{Op: "filter", Predicate: "lt", Value: ptr(0)}}, nil, true},
+		{"min больше max", []TransformOperation{{Op: "clamp", Min: ptr(3), Max: ptr(2)}}, nil, true},
+		{"без параметров", []TransformOperation{{Op: "scale"}}, nil, true},
+		{"неизвестная операция", []TransformOperation{{Op: "rotate"}}, nil, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			numbers := []int{3, 1, 3, 1, 2, 5}
+			got, err := applyPipeline(numbers, c.ops)
+			if c.invalid {
+				if !errors.Is(err, errInvalidEdit) {
+					t.Fatalf("ошибка %v, ожидалась errInvalidEdit", err)
+				}
+				return
+			}
+			if err != nil || !slices.Equal(got, c.want) {
+				t.Fatalf("applyPipeline = %v, %v, ожидалось %v", got, err, c.want)
+			}
+			if !slices.Equal(numbers, []int{3, 1, 3, 1, 2, 5}) {
+				t.Fatal("исходный массив изменен")
+			}
+		})
+	}
+}
+
+// Без seed выбранный seed записывается в операцию, и с ним результат повторяется
+func TestApplyPipelineShuffleSeed(t *testing.T) {
+	numbers := []int{1, 2, 3, 4, 5, 6, 7, 8}
+	ops := []TransformOperation{{Op: "shuffle"}}
+	first, err := applyPipeline(numbers, ops)
+	if err != nil || ops[0].Seed == nil {
+		t.Fatalf("seed не записан: %v", err)
+	}
+	again, _ := applyPipeline(numbers, ops)
+	if !slices.Equal(first, again) {
+		t.Fatalf("с seed %d получены %v и %v", *ops[0].Seed, first, again)
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// trashPurgeInterval - как часто удаляются массивы, пролежавшие в корзине дольше RPS_TRASH_RETENTION
+const trashPurgeInterval = time.Hour
+
+// getTrash возвращает массивы из корзины пользователя u (администратору - все), недавно удаленные первыми
+func getTrash(ctx context.Context, u *User) ([]map[string]interface{}, error) {
+	cond, args := ownerCondition(u)
+	query := `
+		SELECT a.id, a.array_data, a.is_sorted, COALESCE(u.username, ''), a.deleted_at,
+			DATE_ADD(a.deleted_at, INTERVAL ? SECOND)
+		FROM arrays a LEFT JOIN users u ON u.id = a.owner_id
+		WHERE a.deleted_at IS NOT NULL AND ` + cond + `
+		ORDER BY a.deleted_at DESC, a.id`
+	ctx, span := startDBSpan(ctx, "getTrash", query)
+	defer span.End()
+
+	retention := int64(appConfig.TrashRetention / time.Second)
+	rows, err := db.QueryContext(ctx, query, append([]interface{}{retention}, args...)...)
+	if err != nil {
+		return nil, storeError(ctx, "getTrash", err)
+	}
+	defer rows.Close()
+
+	arrays := []map[string]interface{}{}
+	for rows.Next() {
+		var id int
+		var arrayData, owner, deletedAt, purgeAt string
+		var isSorted bool
+		if err := rows.Scan(&id, &arrayData, &isSorted, &owner, &deletedAt, &purgeAt); err != nil {
+			return nil, storeError(ctx, "getTrash", err)
+		}
+
+		numbers, err := parseArrayString(arrayData)
+		if err != nil {
+			numbers = []int{}
+		}
+		item := map[string]interface{}{
+			"id":         id,
+			"array":      numbers,
+			"is_sorted":  isSorted,
+			"owner":      owner,
+			"invalid":    err != nil,
+			"deleted_at": deletedAt,
+		}
+		// Без срока хранения корзина не очищается автоматически
+		if retention > 0 {
+			item["purge_at"] = purgeAt
+		}
+		arrays = append(arrays, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storeError(ctx, "getTrash", err)
+	}
+	return arrays, nil
+}
+
+// restoreArray возвращает массив из корзины
+func restoreArray(ctx context.Context, u *User, id int) error {
+	return inTx(ctx, "restoreArray", func(ctx context.Context, tx *sql.Tx) error {
+		snap, err := lockArray(ctx, tx, u, id, true)
+		if err != nil {
+			return err
+		}
+		if _, err := execTx(ctx, tx, "restoreArray.update", "UPDATE arrays SET deleted_at = NULL WHERE id = ?", id); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, auditEntry{Action: auditRestore, ArrayID: int64(id), After: snap})
+	})
+}
+
+// purgeArray окончательно удаляет массив из корзины
+func purgeArray(ctx context.Context, u *User, id int) error {
+	return inTx(ctx, "purgeArray", func(ctx context.Context, tx *sql.Tx) error {
+		snap, err := lockArray(ctx, tx, u, id, true)
+		if err != nil {
+			return err
+		}
+		if _, err := execTx(ctx, tx, "purgeArray.delete", "DELETE FROM arrays WHERE id = ?", id); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, auditEntry{Action: auditPurge, ArrayID: int64(id), Before: snap})
+	})
+}
+
+// purgeExpiredTrash удаляет массивы, пролежавшие в корзине дольше retention, и возвращает их число
+func purgeExpiredTrash(ctx context.Context, retention time.Duration) (int, error) {
+	var purged int
+	err := inTx(ctx, "purgeExpiredTrash", func(ctx context.Context, tx *sql.Tx) error {
+		query := `
+			SELECT id, array_data, is_sorted, owner_id FROM arrays
+			WHERE deleted_at < DATE_SUB(NOW(), INTERVAL ? SECOND)
+			FOR UPDATE`
+		qctx, span := startDBSpan(ctx, "purgeExpiredTrash.select", query)
+		rows, err := tx.QueryContext(qctx, query, int64(retention/time.Second))
+		if err != nil {
+			span.End()
+			return err
+		}
+		var expired []arraySnapshot
+		for rows.Next() {
+			var id int64
+			var arrayData string
+			var isSorted bool
+			var ownerID sql.NullInt64
+			if err := rows.Scan(&id, &arrayData, &isSorted, &ownerID); err != nil {
+				rows.Close()
+				span.End()
+				return err
+			}
+			expired = append(expired, newArraySnapshot(id, arrayData, isSorted, ownerID))
+		}
+		rows.Close()
+		span.End()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, snap := range expired {
+			if _, err := execTx(ctx, tx, "purgeExpiredTrash.delete", "DELETE FROM arrays WHERE id = ?", snap.ID); err != nil {
+				return err
+			}
+			if err := recordAudit(ctx, tx, auditEntry{Action: auditPurge, ArrayID: snap.ID, Before: snap}); err != nil {
+				return err
+			}
+		}
+		purged = len(expired)
+		return nil
+	})
+	return purged, err
+}
+
+// purgeTrashPeriodically раз в trashPurgeInterval очищает корзину от просроченных массивов
+// и перенумеровывает оставшиеся; завершается с отменой ctx
+func purgeTrashPeriodically(ctx context.Context, retention time.Duration) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+	for {
+		n, err := purgeExpiredTrash(ctx, retention)
+		if err == nil && n > 0 {
+			slog.Info("Корзина очищена", "purged", n)
+			_, err = reindexArrays(ctx)
+		}
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Ошибка очистки корзины", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func trashHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	arrays, err := getTrash(r.Context(), currentUser(r.Context()))
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка при получении корзины: %v", err),
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Data:    arrays,
+	}, http.StatusOK)
+}
+
+func restoreArrayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Неверный ID массива",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	if err := restoreArray(r.Context(), currentUser(r.Context()), id); err != nil {
+		writeTrashError(w, "Ошибка при восстановлении массива", err)
+		return
+	}
+
+	arrayOperationsTotal.inc("restore")
+
+	jsonResponse(w, Response{
+		Success: true,
+		Message: "Массив восстановлен",
+		Data:    map[string]int{"id": id},
+	}, http.StatusOK)
+}
+
+func purgeArrayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" && r.Method != "POST" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: "Неверный ID массива",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	if err := purgeArray(r.Context(), currentUser(r.Context()), id); err != nil {
+		writeTrashError(w, "Ошибка при удалении массива", err)
+		return
+	}
+
+	// После окончательного удаления ID перенумеровываются
+	if _, err := reindexArrays(r.Context()); err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Ошибка переиндексации: %v", err),
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	arrayOperationsTotal.inc("purge")
+
+	jsonResponse(w, Response{
+		Success: true,
+		Message: "Массив удален окончательно",
+	}, http.StatusOK)
+}
+
+// writeTrashError отвечает 404, если массива нет в корзине пользователя, иначе 500
+func writeTrashError(w http.ResponseWriter, prefix string, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, sql.ErrNoRows) {
+		status = http.StatusNotFound
+		err = errors.New("массив не найден в корзине")
+	}
+	jsonResponse(w, Response{
+		Success: false,
+		Message: fmt.Sprintf("%s: %v", prefix, err),
+	}, status)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	roleUser  = "user"
+	roleAdmin = "admin"
+)
+
+// Права доступа. Ключи API и токены получают подмножество прав своего владельца
+const (
+	scopeArraysRead   = "arrays:read"   // просмотр и загрузка массивов
+	scopeArraysWrite  = "arrays:write"  // сохранение, сортировка, удаление
+	scopeAdminReindex = "admin:reindex" // переиндексация всей таблицы
+	scopeAdminAudit   = "admin:audit"   // чтение журнала аудита
+)
+
+// allScopes - все известные права в порядке вывода
+var allScopes = []string{scopeArraysRead, scopeArraysWrite, scopeAdminReindex, scopeAdminAudit}
+
+// User - учетная запись пользователя
+type User struct {
+	ID       int64    `json:"id"`
+	Username string   `json:"username"`
+	Role     string   `json:"role"`
+	Scopes   []string `json:"scopes,omitempty"` // права текущего запроса
+
+	// CSRFToken - токен для заголовка X-CSRF-Token, выдается только при входе по cookie
+	CSRFToken string `json:"csrf_token,omitempty"`
+
+	authMethod string // способ аутентификации текущего запроса: authSession, authAPIKey, authToken
+}
+
+// IsAdmin - администратор видит и изменяет массивы всех пользователей
+func (u *User) IsAdmin() bool {
+	return u != nil && u.Role == roleAdmin
+}
+
+// HasScope проверяет право текущего запроса
+func (u *User) HasScope(scope string) bool {
+	return u != nil && slices.Contains(u.Scopes, scope)
+}
+
+// roleScopes - все права, доступные роли
+func roleScopes(role string) []string {
+	if role == roleAdmin {
+		return allScopes
+	}
+	return []string{scopeArraysRead, scopeArraysWrite}
+}
+
+// grantedScopes оставляет из requested только права, доступные роли.
+// Пустой requested означает все права роли
+func grantedScopes(role string, requested []string) []string {
+	allowed := roleScopes(role)
+	if len(requested) == 0 {
+		return allowed
+	}
+	var granted []string
+	for _, s := range allowed {
+		if slices.Contains(requested, s) {
+			granted = append(granted, s)
+		}
+	}
+	return granted
+}
+
+// validateScopes проверяет, что все права известны и доступны пользователю
+func validateScopes(u *User, scopes []string) error {
+	for _, s := range scopes {
+		if !slices.Contains(allScopes, s) {
+			return fmt.Errorf("неизвестное право %q, доступны: %s", s, strings.Join(allScopes, ", "))
+		}
+		if !u.HasScope(s) {
+			return fmt.Errorf("нельзя выдать право %q, которого нет у вас", s)
+		}
+	}
+	return nil
+}
+
+// errUserExists возвращается при регистрации занятого имени
+var errUserExists = errors.New("пользователь с таким именем уже существует")
+
+func createUser(ctx context.Context, username, passwordHash, role string) (*User, error) {
+	query := "INSERT INTO users (username, password_hash, role) VALUES (?, ?, ?)"
+	ctx, span := startDBSpan(ctx, "createUser", query)
+	defer span.End()
+
+	res, err := db.ExecContext(ctx, query, username, passwordHash, role)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return nil, errUserExists
+		}
+		return nil, storeError(ctx, "createUser", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, storeError(ctx, "createUser", err)
+	}
+	return &User{ID: id, Username: username, Role: role}, nil
+}
+
+// getUserByUsername возвращает пользователя и хеш его пароля
+func getUserByUsername(ctx context.Context, username string) (*User, string, error) {
+	query := "SELECT id, username, role, password_hash FROM users WHERE username = ?"
+	ctx, span := startDBSpan(ctx, "getUserByUsername", query)
+	defer span.End()
+
+	var u User
+	var passwordHash string
+	err := db.QueryRowContext(ctx, query, username).Scan(&u.ID, &u.Username, &u.Role, &passwordHash)
+	if err != nil {
+		return nil, "", storeError(ctx, "getUserByUsername", err)
+	}
+	return &u, passwordHash, nil
+}
+
+// getUserByID возвращает пользователя с текущими именем и ролью
+func getUserByID(ctx context.Context, id int64) (*User, error) {
+	query := "SELECT id, username, role FROM users WHERE id = ?"
+	ctx, span := startDBSpan(ctx, "getUserByID", query)
+	defer span.End()
+
+	var u User
+	if err := db.QueryRowContext(ctx, query, id).Scan(&u.ID, &u.Username, &u.Role); err != nil {
+		return nil, storeError(ctx, "getUserByID", err)
+	}
+	return &u, nil
+}
+
+func setUserPassword(ctx context.Context, userID int64, passwordHash, role string) error {
+	query := "UPDATE users SET password_hash = ?, role = ? WHERE id = ?"
+	ctx, span := startDBSpan(ctx, "setUserPassword", query)
+	defer span.End()
+
+	if _, err := db.ExecContext(ctx, query, passwordHash, role, userID); err != nil {
+		return storeError(ctx, "setUserPassword", err)
+	}
+	return nil
+}
+
+// createSession сохраняет хеш токена сессии; сам токен хранится только в cookie
+func createSession(ctx context.Context, tokenHash string, userID int64, ttlSeconds int64) error {
+	query := "INSERT INTO sessions (token_hash, user_id, expires_at) VALUES (?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))"
+	ctx, span := startDBSpan(ctx, "createSession", query)
+	defer span.End()
+
+	if _, err := db.ExecContext(ctx, query, tokenHash, userID, ttlSeconds); err != nil {
+		return storeError(ctx, "createSession", err)
+	}
+	return nil
+}
+
+// getSessionUser возвращает владельца действующей сессии
+func getSessionUser(ctx context.Context, tokenHash string) (*User, error) {
+	query := `
+		SELECT u.id, u.username, u.role
+		FROM sessions s JOIN users u ON u.id = s.user_id
+		WHERE s.token_hash = ? AND s.expires_at > NOW()`
+	ctx, span := startDBSpan(ctx, "getSessionUser", query)
+	defer span.End()
+
+	var u User
+	err := db.QueryRowContext(ctx, query, tokenHash).Scan(&u.ID, &u.Username, &u.Role)
+	if err != nil {
+		return nil, storeError(ctx, "getSessionUser", err)
+	}
+	return &u, nil
+}
+
+func deleteSession(ctx context.Context, tokenHash string) error {
+	query := "DELETE FROM sessions WHERE token_hash = ?"
+	ctx, span := startDBSpan(ctx, "deleteSession", query)
+	defer span.End()
+
+	if _, err := db.ExecContext(ctx, query, tokenHash); err != nil {
+		return storeError(ctx, "deleteSession", err)
+	}
+	return nil
+}
+
+func deleteExpiredSessions(ctx context.Context) error {
+	query := "DELETE FROM sessions WHERE expires_at <= NOW()"
+	ctx, span := startDBSpan(ctx, "deleteExpiredSessions", query)
+	defer span.End()
+
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return storeError(ctx, "deleteExpiredSessions", err)
+	}
+	return nil
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Причины появления версии массива; кроме create совпадают с действием в журнале аудита
+const (
+	versionCreate    = "create"
+	versionSort      = auditSort
+	versionEdit      = auditEdit
+	versionRevert    = auditRevert
+	versionTransform = auditTransform
+)
+
+// maxDiffCells - предел размера таблицы LCS (длина * длина отличающихся частей).
+// Для больших массивов отличающаяся середина выдается целиком как удаление и вставка
+const maxDiffCells = 4000000
+
+// ArrayVersion - версия массива в истории
+type ArrayVersion struct {
+	Version      int    `json:"version"`
+	Array        []int  `json:"array"`
+	IsSorted     bool   `json:"is_sorted"`
+	Action       string `json:"action"`
+	Algorithm    string `json:"algorithm,omitempty"`
+	RevertedFrom int    `json:"reverted_from,omitempty"`
+	Author       string `json:"author,omitempty"`
+	CreatedAt    string `json:"created_at"`
+	Current      bool   `json:"current"`
+	Invalid      bool   `json:"invalid,omitempty"`
+}
+
+// versionChange описывает новую версию: что ее создало
+type versionChange struct {
+	Action       string // versionSort, versionEdit, versionRevert
+	Algorithm    string // для versionSort
+	RevertedFrom int    // для versionRevert
+}
+
+// formatArray - строковое представление массива для столбца array_data
+func formatArray(numbers []int) string {
+	var sb strings.Builder
+	for i, num := range numbers {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(strconv.Itoa(num))
+	}
+	return sb.String()
+}
+
+// insertVersion добавляет строку истории array_versions
+func insertVersion(ctx context.Context, tx *sql.Tx, arrayID int64, version int, arrayData string, isSorted bool, change versionChange) error {
+	var authorID sql.NullInt64
+	if u := currentUser(ctx); u != nil {
+		authorID = sql.NullInt64{Int64: u.ID, Valid: true}
+	}
+	_, err := execTx(ctx, tx, "insertVersion", `
+		INSERT INTO array_versions (array_id, version, array_data, is_sorted, action, algorithm, reverted_from, author_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		arrayID, version, arrayData, isSorted, change.Action,
+		sql.NullString{String: change.Algorithm, Valid: change.Algorithm != ""},
+		sql.NullInt64{Int64: int64(change.RevertedFrom), Valid: change.RevertedFrom != 0},
+		authorID)
+	return err
+}
+
+// updateArrayVersion заменяет содержимое массива новой версией, сохраняя предыдущую в истории,
+// и возвращает номер новой версии
+func updateArrayVersion(ctx context.Context, u *User, id int, numbers []int, change versionChange) (int, error) {
+	version, _, err := editArray(ctx, u, id, change, func(arraySnapshot) ([]int, error) {
+		return numbers, nil
+	})
+	return version, err
+}
+
+// editArray вычисляет новое содержимое массива функцией edit от текущего и сохраняет его новой версией.
+// Строка массива заблокирована на время edit, поэтому параллельные изменения не теряются.
+// Возвращает номер новой версии и новое содержимое; ошибка edit отменяет изменение.
+// is_sorted вычисляется по новому содержимому
+func editArray(ctx context.Context, u *User, id int, change versionChange,
+	edit func(current arraySnapshot) ([]int, error)) (int, []int, error) {
+	var version int
+	var numbers []int
+	err := inTx(ctx, "editArray", func(ctx context.Context, tx *sql.Tx) error {
+		before, err := lockArray(ctx, tx, u, id, false)
+		if err != nil {
+			return err
+		}
+		if numbers, err = edit(before); err != nil {
+			return err
+		}
+		isSorted := isSortedAsc(numbers)
+		version = before.Version + 1
+
+		arrayStr := formatArray(numbers)
+		args := append([]interface{}{arrayStr, isSorted, version}, summaryColumns(numbers)...)
+		_, err = execTx(ctx, tx, "editArray.update", `
+			UPDATE arrays SET array_data = ?, is_sorted = ?, version = ?,
+				length = ?, sum = ?, min_value = ?, max_value = ?, mean = ?, content_hash = ?, elements_hash = ?,
+				sort_order = ?, inversions = ?, nearly_sorted = ?
+			WHERE id = ?`, append(args, id)...)
+		if err != nil {
+			return err
+		}
+		if err := insertVersion(ctx, tx, int64(id), version, arrayStr, isSorted, change); err != nil {
+			return err
+		}
+		if err := writeArrayElements(ctx, tx, int64(id), numbers); err != nil {
+			return err
+		}
+
+		after := before
+		after.Array, after.ArrayData, after.IsSorted, after.Version = numbers, "", isSorted, version
+		audit := auditEntry{Action: change.Action, ArrayID: int64(id), Before: before, After: after}
+		return recordAudit(ctx, tx, audit)
+	})
+	return version, numbers, err
+}
+
+// listVersions возвращает историю массива от первой версии к последней
+func listVersions(ctx context.Context, u *User, id int) ([]ArrayVersion, error) {
+	cond, args := ownerCondition(u)
+	query := `
+		SELECT v.version, v.array_data, v.is_sorted, v.action, COALESCE(v.algorithm, ''),
+			COALESCE(v.reverted_from, 0), COALESCE(au.username, ''), v.created_at, v.version = a.version
+		FROM array_versions v
+		JOIN arrays a ON a.id = v.array_id
+		LEFT JOIN users au ON au.id = v.author_id
+		WHERE a.id = ? AND a.deleted_at IS NULL AND ` + cond + `
+		ORDER BY v.version`
+	ctx, span := startDBSpan(ctx, "listVersions", query)
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, query, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return nil, storeError(ctx, "listVersions", err)
+	}
+	defer rows.Close()
+
+	var versions []ArrayVersion
+	for rows.Next() {
+		var v ArrayVersion
+		var arrayData string
+		err := rows.Scan(&v.Version, &arrayData, &v.IsSorted, &v.Action, &v.Algorithm,
+			&v.RevertedFrom, &v.Author, &v.CreatedAt, &v.Current)
+		if err != nil {
+			return nil, storeError(ctx, "listVersions", err)
+		}
+		if v.Array, err = parseArrayString(arrayData); err != nil {
+			v.Array, v.Invalid = []int{}, true
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, storeError(ctx, "listVersions", err)
+	}
+	if len(versions) > 0 {
+		return versions, nil
+	}
+
+	// У массива, добавленного в обход API, истории нет: его текущее содержимое - единственная версия
+	numbers, err := getArrayByID(ctx, u, id)
+	if err != nil && !errors.Is(err, errInvalidArrayData) {
+		return nil, err
+	}
+	current := ArrayVersion{Version: 1, Array: numbers, Action: versionCreate, Current: true, Invalid: err != nil}
+	if current.Invalid {
+		current.Array = []int{}
+	}
+	return []ArrayVersion{current}, nil
+}
+
+// findVersion ищет версию по номеру; 0 - текущая версия
+func findVersion(versions []ArrayVersion, version int) (ArrayVersion, bool) {
+	for _, v := range versions {
+		if v.Version == version || version == 0 && v.Current {
+			return v, true
+		}
+	}
+	return ArrayVersion{}, false
+}
+
+// diffOp - участок разницы между версиями: equal, delete (есть только в from) или insert (только в to)
+type diffOp struct {
+	Op     string `json:"op"`
+	Values []int  `json:"values"`
+}
+
+// diffArrays строит поэлементную разницу a -> b: общие начало и конец отбрасываются,
+// середина сравнивается по наибольшей общей подпоследовательности (LCS)
+func diffArrays(a, b []int) []diffOp {
+	var ops []diffOp
+	add := func(op string, v int) {
+		if n := len(ops); n > 0 && ops[n-1].Op == op {
+			ops[n-1].Values = append(ops[n-1].Values, v)
+			return
+		}
+		ops = append(ops, diffOp{Op: op, Values: []int{v}})
+	}
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for _, v := range a[:prefix] {
+		add("equal", v)
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) <= maxDiffCells {
+		// lcs[i][j] - длина LCS для midA[i:] и midB[j:]
+		n, m := len(midA), len(midB)
+		lcs := make([][]int32, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int32, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < n && j < m {
+			switch {
+			case midA[i] == midB[j]:
+				add("equal", midA[i])
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				add("delete", midA[i])
+				i++
+			default:
+				add("insert", midB[j])
+				j++
+			}
+		}
+		for ; i < n; i++ {
+			add("delete", midA[i])
+		}
+		for ; j < m; j++ {
+			add("insert", midB[j])
+		}
+	} else {
+		for _, v := range midA {
+			add("delete", v)
+		}
+		for _, v := range midB {
+			add("insert", v)
+		}
+	}
+
+	for _, v := range a[len(a)-suffix:] {
+		add("equal", v)
+	}
+	return ops
+}
+
+// versionParams читает обязательный параметр id и необязательные номера версий names (0 - не указан)
+func versionParams(r *http.Request, names ...string) (int, []int, error) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		return 0, nil, errors.New("Неверный ID массива")
+	}
+	versions := make([]int, len(names))
+	for i, name := range names {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+		if versions[i], err = strconv.Atoi(v); err != nil || versions[i] <= 0 {
+			return 0, nil, fmt.Errorf("Неверный номер версии %s", name)
+		}
+	}
+	return id, versions, nil
+}
+
+// historyHandler - список версий массива
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, _, err := versionParams(r)
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	versions, err := listVersions(r.Context(), currentUser(r.Context()), id)
+	if err != nil {
+		writeLoadError(w, err)
+		return
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Data:    versions,
+	}, http.StatusOK)
+}
+
+// diffHandler - разница между версиями from и to (по умолчанию предыдущая и текущая)
+func diffHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, nums, err := versionParams(r, "from", "to")
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	versions, err := listVersions(r.Context(), currentUser(r.Context()), id)
+	if err != nil {
+		writeLoadError(w, err)
+		return
+	}
+
+	to, ok := findVersion(versions, nums[1])
+	if !ok {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Версия %d не найдена", nums[1]),
+		}, http.StatusNotFound)
+		return
+	}
+	fromNum := nums[0]
+	if fromNum == 0 {
+		fromNum = max(to.Version-1, 1)
+	}
+	from, ok := findVersion(versions, fromNum)
+	if !ok {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Версия %d не найдена", fromNum),
+		}, http.StatusNotFound)
+		return
+	}
+
+	ops := diffArrays(from.Array, to.Array)
+	var inserted, deleted int
+	for _, op := range ops {
+		switch op.Op {
+		case "insert":
+			inserted += len(op.Values)
+		case "delete":
+			deleted += len(op.Values)
+		}
+	}
+
+	jsonResponse(w, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"from":     from.Version,
+			"to":       to.Version,
+			"inserted": inserted,
+			"deleted":  deleted,
+			"ops":      ops,
+		},
+	}, http.StatusOK)
+}
+
+// revertHandler делает версию version текущей, создавая новую версию с ее содержимым
+func revertHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, nums, err := versionParams(r, "version")
+	if err == nil && nums[0] == 0 {
+		err = errors.New("Не указан номер версии")
+	}
+	if err != nil {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	u := currentUser(r.Context())
+	versions, err := listVersions(r.Context(), u, id)
+	if err != nil {
+		writeLoadError(w, err)
+		return
+	}
+	target, ok := findVersion(versions, nums[0])
+	if !ok {
+		jsonResponse(w, Response{
+			Success: false,
+			Message: fmt.Sprintf("Версия %d не найдена", nums[0]),
+		}, http.StatusNotFound)
+		return
+	}
+	if target.Invalid {
+		writeLoadError(w, errInvalidArrayData)
+		return
+	}
+
+	version, err := updateArrayVersion(r.Context(), u, id, target.Array, versionChange{Action: versionRevert, RevertedFrom: target.Version})
+	if err != nil {
+		writeEditError(w, err)
+		return
+	}
+
+	arrayOperationsTotal.inc("revert")
+
+	jsonResponse(w, Response{
+		Success: true,
+		Message: fmt.Sprintf("Массив возвращен к версии %d (новая версия %d)", target.Version, version),
+		Data:    map[string]int{"id": id, "version": version},
+	}, http.StatusOK)
+}
@@ -0,0 +1,9 @@
+// Пакет frontend встраивает файлы веб-интерфейса в бинарник сервера
+package frontend
+
+import "embed"
+
+// Files - index.html, скрипты, стили и изображения интерфейса
+//
+//go:embed index.html script.js styles.css img
+var Files embed.FS
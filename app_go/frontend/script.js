@@ -5,12 +5,48 @@ document.addEventListener('DOMContentLoaded', function() {
         arrayInput: document.getElementById('array-input'),
         sortBtn: document.getElementById('sort-btn'),
         saveBtn: document.getElementById('save-btn'),
+        updateBtn: document.getElementById('update-btn'),
+        updateLabel: document.getElementById('update-label'),
         clearBtn: document.getElementById('clear-btn'),
+        generateBtn: document.getElementById('generate-btn'),
+        genSize: document.getElementById('gen-size'),
+        genMin: document.getElementById('gen-min'),
+        genMax: document.getElementById('gen-max'),
+        genDistribution: document.getElementById('gen-distribution'),
+        genSeed: document.getElementById('gen-seed'),
+        genInfo: document.getElementById('gen-info'),
         resultContainer: document.getElementById('result-container'),
         arraysList: document.getElementById('arrays-list'),
-        inputError: document.getElementById('input-error')
+        inputError: document.getElementById('input-error'),
+        authForm: document.getElementById('auth-form'),
+        authStatus: document.getElementById('auth-status'),
+        authUser: document.getElementById('auth-user'),
+        authUsername: document.getElementById('auth-username'),
+        authPassword: document.getElementById('auth-password'),
+        authError: document.getElementById('auth-error'),
+        loginBtn: document.getElementById('login-btn'),
+        registerBtn: document.getElementById('register-btn'),
+        logoutBtn: document.getElementById('logout-btn'),
+        trashSection: document.getElementById('trash-section'),
+        trashList: document.getElementById('trash-list'),
+        undoNotice: document.getElementById('undo-notice'),
+        undoText: document.getElementById('undo-text'),
+        undoBtn: document.getElementById('undo-btn')
     };
 
+    // Сколько показывается кнопка отмены удаления, мс
+    const UNDO_TIMEOUT = 10000;
+
+    // Пользователь, выполнивший вход (null - не выполнен)
+    let currentUser = null;
+
+    // Последний удаленный массив, который можно вернуть кнопкой "Отменить"
+    let undoID = null;
+    let undoTimer = null;
+
+    // Массив, загруженный в поле ввода: кнопка "Обновить" заменяет его содержимое
+    let editingID = null;
+
     // Делегирование событий для динамических кнопок
     elements.arraysList.addEventListener('click', function(e) {
         const target = e.target;
@@ -20,28 +56,123 @@ document.addEventListener('DOMContentLoaded', function() {
             sortAndSaveArray(target.dataset.id);
         } else if (target.classList.contains('delete-btn')) {
             deleteArray(target.dataset.id);
+        } else if (target.classList.contains('stats-btn')) {
+            toggleStats(target.dataset.id);
+        } else if (target.classList.contains('history-btn')) {
+            toggleHistory(target.dataset.id);
+        } else if (target.classList.contains('revert-btn')) {
+            revertArray(target.dataset.id, target.dataset.version);
+        }
+    });
+
+    elements.trashList.addEventListener('click', function(e) {
+        const target = e.target;
+        if (target.classList.contains('restore-btn')) {
+            restoreArray(target.dataset.id);
+        } else if (target.classList.contains('purge-btn')) {
+            purgeArray(target.dataset.id);
+        }
+    });
+
+    elements.undoBtn.addEventListener('click', function() {
+        const id = undoID;
+        hideUndo();
+        if (id !== null) {
+            restoreArray(id);
         }
     });
 
     // Обработчики кнопок
     elements.sortBtn.addEventListener('click', sortArray);
     elements.saveBtn.addEventListener('click', saveArray);
+    elements.updateBtn.addEventListener('click', updateArray);
     elements.clearBtn.addEventListener('click', clearInput);
+    elements.generateBtn.addEventListener('click', generateArray);
+    elements.loginBtn.addEventListener('click', () => authenticate('/auth/login'));
+    elements.registerBtn.addEventListener('click', () => authenticate('/auth/register'));
+    elements.logoutBtn.addEventListener('click', logout);
 
-    // Загрузка данных при старте
-    loadArrays();
+    // Проверка сессии и загрузка данных при старте
+    checkSession();
 
-    async function deleteArray(id) {
-        if (!confirm('Вы уверены, что хотите удалить этот массив?')) {
-            return;
+    async function checkSession() {
+        try {
+            const response = await fetch('/auth/me');
+            const data = await response.json();
+            setUser(response.ok ? data.data : null);
+        } catch (error) {
+            console.error('Error:', error);
+            setUser(null);
         }
-        
+    }
+
+    // Вход или регистрация: сервер в обоих случаях выставляет cookie сессии
+    async function authenticate(url) {
+        try {
+            const response = await fetch(url, {
+                method: 'POST',
+                headers: requestHeaders(),
+                body: JSON.stringify({
+                    username: elements.authUsername.value.trim(),
+                    password: elements.authPassword.value
+                })
+            });
+
+            const data = await response.json();
+
+            if (!response.ok) {
+                throw new Error(data.message || 'Ошибка сервера');
+            }
+
+            elements.authPassword.value = '';
+            elements.authError.textContent = '';
+            setUser(data.data);
+        } catch (error) {
+            console.error('Error:', error);
+            elements.authError.textContent = error.message;
+        }
+    }
+
+    // Заголовки изменяющих запросов: сервер требует CSRF-токен текущей сессии
+    function requestHeaders() {
+        const headers = { 'Content-Type': 'application/json' };
+        if (currentUser && currentUser.csrf_token) {
+            headers['X-CSRF-Token'] = currentUser.csrf_token;
+        }
+        return headers;
+    }
+
+    async function logout() {
+        try {
+            await fetch('/auth/logout', { method: 'POST', headers: requestHeaders() });
+        } catch (error) {
+            console.error('Error:', error);
+        }
+        setUser(null);
+    }
+
+    function setUser(user) {
+        currentUser = user;
+        elements.authForm.hidden = !!user;
+        elements.authStatus.hidden = !user;
+        elements.authUser.textContent = user ? `${user.username}${user.role === 'admin' ? ' (администратор)' : ''}` : '';
+
+        elements.trashSection.hidden = !user;
+
+        if (user) {
+            loadArrays();
+        } else {
+            hideUndo();
+            setMessage(elements.arraysList, 'Войдите, чтобы сохранять массивы и видеть сохраненные');
+        }
+    }
+
+    // Удаление перемещает массив в корзину; его можно вернуть кнопкой "Отменить" или из корзины
+    async function deleteArray(id) {
         try {
             const response = await fetch(`/arrays/delete?id=${id}`, { // http запрос к серверу
                 method: 'DELETE',
-                headers: {
-                    'Content-Type': 'application/json'
-                }
+                headers: requestHeaders()
             });
             
             const data = await response.json(); // Обработка ответа сервера
@@ -50,7 +181,7 @@ document.addEventListener('DOMContentLoaded', function() {
                 throw new Error(data.message || 'Ошибка сервера');
             }
             
-            alert('Массив успешно удален!');
+            showUndo(id);
             loadArrays();
         } catch (error) {
             console.error('Error:', error);
@@ -58,6 +189,113 @@ document.addEventListener('DOMContentLoaded', function() {
         }
     }
 
+    function showUndo(id) {
+        clearTimeout(undoTimer);
+        undoID = id;
+        elements.undoText.textContent = `Массив #${id} перемещен в корзину`;
+        elements.undoNotice.hidden = false;
+        undoTimer = setTimeout(hideUndo, UNDO_TIMEOUT);
+    }
+
+    function hideUndo() {
+        clearTimeout(undoTimer);
+        undoID = null;
+        elements.undoNotice.hidden = true;
+    }
+
+    async function restoreArray(id) {
+        try {
+            const response = await fetch(`/arrays/restore?id=${id}`, {
+                method: 'POST',
+                headers: requestHeaders()
+            });
+
+            const data = await response.json();
+
+            if (!response.ok) {
+                throw new Error(data.message || 'Ошибка сервера');
+            }
+
+            loadArrays();
+        } catch (error) {
+            console.error('Error:', error);
+            showError(error.message);
+        }
+    }
+
+    async function purgeArray(id) {
+        if (!confirm('Удалить массив окончательно? Это действие нельзя отменить.')) {
+            return;
+        }
+
+        try {
+            const response = await fetch(`/arrays/purge?id=${id}`, {
+                method: 'DELETE',
+                headers: requestHeaders()
+            });
+
+            const data = await response.json();
+
+            if (!response.ok) {
+                throw new Error(data.message || 'Ошибка сервера');
+            }
+
+            loadArrays();
+        } catch (error) {
+            console.error('Error:', error);
+            showError(error.message);
+        }
+    }
+
+    async function loadTrash() {
+        try {
+            const response = await fetch('/arrays/trash');
+            const data = await response.json();
+
+            if (!response.ok) {
+                throw new Error(data.message || 'Ошибка сервера');
+            }
+
+            renderTrash(data.data);
+        } catch (error) {
+            console.error('Error:', error);
+            setMessage(elements.trashList, 'Ошибка загрузки корзины');
+        }
+    }
+
+    function renderTrash(arrays) {
+        elements.trashList.replaceChildren();
+
+        if (!Array.isArray(arrays) || arrays.length === 0) {
+            setMessage(elements.trashList, 'Корзина пуста');
+            return;
+        }
+
+        arrays.forEach(arr => {
+            const numbers = Array.isArray(arr.array) ? arr.array.filter(Number.isFinite) : [];
+            const id = Number(arr.id);
+
+            const item = document.createElement('div');
+            item.className = 'array-item trash-item';
+            appendElement(item, 'h3', `Массив #${id}`);
+            appendElement(item, 'p', arr.invalid ? 'Данные массива повреждены' : numbers.join(','));
+            appendElement(item, 'p', `Удален: ${arr.deleted_at}`);
+            if (arr.purge_at) {
+                appendElement(item, 'p', `Будет удален окончательно: ${arr.purge_at}`);
+            }
+            if (currentUser && currentUser.role === 'admin') {
+                appendElement(item, 'p', `Владелец: ${arr.owner || '—'}`);
+            }
+
+            const actions = appendElement(item, 'div', undefined, 'array-actions');
+            [['restore-btn', 'Восстановить'], ['purge-btn', 'Удалить навсегда']].forEach(([className, label]) => {
+                const button = appendElement(actions, 'button', label, className);
+                button.dataset.id = id;
+            });
+            elements.trashList.appendChild(item);
+        });
+    }
+
     function sortArray() {
         try {
             // Получаем строку из input поля и преобразуем в массив
@@ -65,12 +303,11 @@ document.addEventListener('DOMContentLoaded', function() {
             // Сортируем созданную копию массива ([...array])
             const sortedArray = selectionSort([...array]);
             
-            elements.resultContainer.innerHTML = `
-                <h3>Исходный массив:</h3>
-                <p>[${array.join(', ')}]</p>
-                <h3>Отсортированный массив:</h3>
-                <p>[${sortedArray.join(', ')}]</p>
-            `;
+            elements.resultContainer.replaceChildren();
+            appendElement(elements.resultContainer, 'h3', 'Исходный массив:');
+            appendElement(elements.resultContainer, 'p', `[${array.join(', ')}]`);
+            appendElement(elements.resultContainer, 'h3', 'Отсортированный массив:');
+            appendElement(elements.resultContainer, 'p', `[${sortedArray.join(', ')}]`);
 
             clearError();
         } catch (e) {
@@ -78,35 +315,72 @@ document.addEventListener('DOMContentLoaded', function() {
         }
     }
 
+    // appendElement создает элемент с текстом и добавляет его в parent.
+    // Текст задается через textContent, поэтому данные с сервера не разбираются как HTML
+    function appendElement(parent, tag, text, className) {
+        const el = document.createElement(tag);
+        if (text !== undefined) el.textContent = text;
+        if (className) el.className = className;
+        parent.appendChild(el);
+        return el;
+    }
+
+    // setMessage заменяет содержимое контейнера одним абзацем текста
+    function setMessage(container, text) {
+        container.replaceChildren();
+        appendElement(container, 'p', text);
+    }
+
     function renderArrays(arrays) {
-        elements.arraysList.innerHTML = '';
+        elements.arraysList.replaceChildren();
         
         if (!Array.isArray(arrays) || arrays.length === 0) {
-            elements.arraysList.innerHTML = '<p>Нет сохраненных массивов</p>';
+            setMessage(elements.arraysList, 'Нет сохраненных массивов');
             return;
         }
         
         // forEach - выполнение для каждого элемента массива
         arrays.sort((a, b) => a.id - b.id).forEach(arr => {
+            // Сервер отдает элементы массивом чисел; все остальное не выводим
+            const numbers = Array.isArray(arr.array) ? arr.array.filter(Number.isFinite) : [];
+            const id = Number(arr.id);
+
             // div - division
             const arrayItem = document.createElement('div');
             arrayItem.className = 'array-item';
-            // .inerHTML - св-во, позволяющее получить содержимое в виде строки или установиь новое
-            arrayItem.innerHTML = `
-                <h3>Массив #${arr.id}</h3>
-                <p>${arr.array_data}</p>
-                <p>Статус: ${arr.is_sorted ? 'Отсортирован' : 'Не отсортирован'}</p>
-                <div class="array-actions">
-                    <button data-id="${arr.id}" class="load-btn">Загрузить</button>
-                    <button data-id="${arr.id}" class="sort-btn">Сортировать</button>
-                    <button data-id="${arr.id}" class="delete-btn">Удалить</button>
-                </div>
-            `;
+            appendElement(arrayItem, 'h3', `Массив #${id}`);
+            appendElement(arrayItem, 'p', arr.invalid ? 'Данные массива повреждены' : numbers.join(','));
+            appendElement(arrayItem, 'p', `Статус: ${orderStatus(arr)}`);
+            appendElement(arrayItem, 'p', `Версия: ${Number(arr.version) || 1}`);
+            if (currentUser && currentUser.role === 'admin') {
+                appendElement(arrayItem, 'p', `Владелец: ${arr.owner || '—'}`);
+            }
+
+            const actions = appendElement(arrayItem, 'div', undefined, 'array-actions');
+            [['load-btn', 'Загрузить'], ['sort-btn', 'Сортировать'], ['stats-btn', 'Статистика'], ['history-btn', 'История'], ['delete-btn', 'Удалить']].forEach(([className, label]) => {
+                const button = appendElement(actions, 'button', label, className);
+                button.dataset.id = id;
+            });
+            const stats = appendElement(arrayItem, 'div', undefined, 'array-stats');
+            stats.id = `stats-${id}`;
+            stats.hidden = true;
+            const history = appendElement(arrayItem, 'div', undefined, 'array-history');
+            history.id = `history-${id}`;
+            history.hidden = true;
             // добавляем в DOM в конец дочерних эл-ов
             elements.arraysList.appendChild(arrayItem);
         });
     }
 
+    // orderStatus описывает упорядоченность, вычисленную сервером
+    function orderStatus(arr) {
+        if (arr.is_sorted) return 'Отсортирован';
+        if (arr.order === 'desc') return 'Отсортирован по убыванию';
+        const inversions = Number(arr.inversions) || 0;
+        if (arr.nearly_sorted) return `Почти отсортирован (инверсий: ${inversions})`;
+        return `Не отсортирован (инверсий: ${inversions})`;
+    }
+
     async function saveArray() {
         try {
             // .trim() - удаляет пробелы в начале и коуе строки
@@ -114,19 +388,17 @@ document.addEventListener('DOMContentLoaded', function() {
 
             // /.../ - ограничение выражения
             // ^ - начало строки
-            // \d+ - одна или юолее цифр
-            // (\s*,\s*\d+) - группа символов, (ноль или более пробелов , нибп одно или более цифр)
+            // -?\d+ - одна или юолее цифр, возможно со знаком минус
+            // (\s*,\s*-?\d+) - группа символов, (ноль или более пробелов , нибп одно или более цифр)
             // .test - проверка на соответствие
-            if (!/^\d+(\s*,\s*\d+)*$/.test(input)) {
+            if (!/^-?\d+(\s*,\s*-?\d+)*$/.test(input)) {
                 throw new Error('Используйте формат: "123, 22, 111"');
             }
     
             // fetch - POST-запрос
             const response = await fetch('/arrays/save', {
                 method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json',
-                },
+                headers: requestHeaders(),
                 body: JSON.stringify({ 
                     array: input,
                     isSorted: false 
@@ -139,7 +411,8 @@ document.addEventListener('DOMContentLoaded', function() {
                 throw new Error(result.message || 'Ошибка сохранения');
             }
             
-            alert('Массив сохранен!');
+            // При RPS_DUPLICATES=merge повтор не сохраняется, сервер сообщает ID существующего массива
+            alert(result.code === 'duplicate_array' ? result.message : 'Массив сохранен!');
             loadArrays();
         } catch (error) {
             console.error('Ошибка:', error);
@@ -151,12 +424,19 @@ document.addEventListener('DOMContentLoaded', function() {
         try {
             const response = await fetch('/arrays');
             const data = await response.json();
+
+            // Сессия истекла
+            if (response.status === 401) {
+                setUser(null);
+                return;
+            }
             
             if (!response.ok) {
                 throw new Error(data.message || 'Ошибка сервера');
             }
             
             renderArrays(data.data);
+            loadTrash();
         } catch (error) {
             console.error('Error:', error);
             showError('Ошибка загрузки массивов');
@@ -172,13 +452,14 @@ document.addEventListener('DOMContentLoaded', function() {
                 throw new Error(data.message || 'Ошибка сервера');
             }
             
-            const arrayData = data.array || data.array_data || data.data?.array;
-            if (!arrayData) {
+            const numbers = data.data?.array;
+            if (!Array.isArray(numbers) || !numbers.every(Number.isFinite)) {
                 throw new Error('Неверный формат данных массива');
             }
             
-            elements.arrayInput.value = arrayData;
-            elements.resultContainer.innerHTML = '';
+            elements.arrayInput.value = numbers.join(', ');
+            elements.resultContainer.replaceChildren();
+            setEditing(Number(id));
             clearError();
         } catch (error) {
             console.error('Error:', error);
@@ -186,13 +467,141 @@ document.addEventListener('DOMContentLoaded', function() {
         }
     }
 
+    async function toggleStats(id) {
+        const container = document.getElementById(`stats-${Number(id)}`);
+        if (!container) return;
+        if (!container.hidden) {
+            container.hidden = true;
+            return;
+        }
+
+        try {
+            const response = await fetch(`/arrays/stats?id=${id}`);
+            const data = await response.json();
+
+            if (!response.ok) {
+                throw new Error(data.message || 'Ошибка сервера');
+            }
+
+            renderStats(container, data.data);
+            container.hidden = false;
+        } catch (error) {
+            console.error('Error:', error);
+            showError(error.message);
+        }
+    }
+
+    function renderStats(container, st) {
+        container.replaceChildren();
+        const round = v => Number(Number(v).toFixed(2));
+        [
+            `Элементов: ${Number(st.length)}, сумма: ${Number(st.sum)}`,
+            `Минимум: ${Number(st.min)}, максимум: ${Number(st.max)}`,
+            `Среднее: ${round(st.mean)}, медиана: ${round(st.median)}`,
+            `Мода: ${(st.mode || []).map(Number).join(', ')} (${Number(st.mode_count)} раз)`,
+            `Дисперсия: ${round(st.variance)}, ст. отклонение: ${round(st.std_dev)}`,
+            `Перцентили: ${(st.percentiles || []).map(p => `p${Number(p.p)}=${round(p.value)}`).join(', ')}`
+        ].forEach(line => appendElement(container, 'p', line));
+
+        const bins = st.histogram || [];
+        const maxCount = Math.max(1, ...bins.map(b => Number(b.count)));
+        const chart = appendElement(container, 'div', undefined, 'histogram');
+        bins.forEach(b => {
+            const row = appendElement(chart, 'div', undefined, 'histogram-row');
+            appendElement(row, 'span', `${round(b.from)}–${round(b.to)}`, 'histogram-label');
+            const bar = appendElement(row, 'span', undefined, 'histogram-bar');
+            bar.style.width = `${Number(b.count) / maxCount * 100}%`;
+            appendElement(row, 'span', String(Number(b.count)), 'histogram-count');
+        });
+    }
+
+    // Названия действий, создавших версию
+    const VERSION_ACTIONS = {
+        create: 'создан',
+        sort: 'отсортирован',
+        edit: 'изменен',
+        revert: 'возврат',
+        transform: 'преобразован'
+    };
+
+    async function toggleHistory(id) {
+        const container = document.getElementById(`history-${Number(id)}`);
+        if (!container) return;
+        if (!container.hidden) {
+            container.hidden = true;
+            return;
+        }
+
+        try {
+            const response = await fetch(`/arrays/history?id=${id}`);
+            const data = await response.json();
+
+            if (!response.ok) {
+                throw new Error(data.message || 'Ошибка сервера');
+            }
+
+            renderHistory(container, Number(id), data.data);
+            container.hidden = false;
+        } catch (error) {
+            console.error('Error:', error);
+            showError(error.message);
+        }
+    }
+
+    function renderHistory(container, id, versions) {
+        container.replaceChildren();
+        if (!Array.isArray(versions) || versions.length === 0) {
+            setMessage(container, 'История пуста');
+            return;
+        }
+
+        // Новые версии первыми
+        [...versions].reverse().forEach(v => {
+            const version = Number(v.version);
+            const numbers = Array.isArray(v.array) ? v.array.filter(Number.isFinite) : [];
+
+            let title = `Версия ${version}: ${VERSION_ACTIONS[v.action] || v.action}`;
+            if (v.algorithm) title += ` (${v.algorithm})`;
+            if (v.reverted_from) title += ` к версии ${Number(v.reverted_from)}`;
+            if (v.current) title += ' — текущая';
+
+            const item = appendElement(container, 'div', undefined, 'version-item');
+            appendElement(item, 'h4', title);
+            appendElement(item, 'p', `${v.created_at}${v.author ? ', ' + v.author : ''}`, 'version-meta');
+            appendElement(item, 'p', v.invalid ? 'Данные массива повреждены' : numbers.join(','));
+            if (!v.current && !v.invalid) {
+                const button = appendElement(item, 'button', 'Вернуть', 'revert-btn');
+                button.dataset.id = id;
+                button.dataset.version = version;
+            }
+        });
+    }
+
+    async function revertArray(id, version) {
+        try {
+            const response = await fetch(`/arrays/revert?id=${id}&version=${version}`, {
+                method: 'POST',
+                headers: requestHeaders()
+            });
+
+            const data = await response.json();
+
+            if (!response.ok) {
+                throw new Error(data.message || 'Ошибка сервера');
+            }
+
+            loadArrays();
+        } catch (error) {
+            console.error('Error:', error);
+            showError(error.message);
+        }
+    }
+
     async function sortAndSaveArray(id) {
         try {
             const response = await fetch(`/arrays/sort?id=${id}`, { 
                 method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json'
-                }
+                headers: requestHeaders()
             });
             
             const data = await response.json();
@@ -209,9 +618,92 @@ document.addEventListener('DOMContentLoaded', function() {
         }
     }
 
+    function setEditing(id) {
+        editingID = id;
+        elements.updateBtn.hidden = id === null;
+        if (id !== null) {
+            elements.updateLabel.textContent = `Обновить #${id}`;
+        }
+    }
+
+    // updateArray заменяет содержимое загруженного массива (PUT): это новая версия того же массива
+    async function updateArray() {
+        if (editingID === null) return;
+
+        try {
+            const input = elements.arrayInput.value.trim();
+            if (!/^-?\d+(\s*,\s*-?\d+)*$/.test(input)) {
+                throw new Error('Используйте формат: "123, 22, 111"');
+            }
+
+            const response = await fetch(`/arrays/update?id=${editingID}`, {
+                method: 'PUT',
+                headers: requestHeaders(),
+                body: JSON.stringify({ array: input })
+            });
+
+            const data = await response.json();
+
+            if (!response.ok) {
+                throw new Error(data.message || 'Ошибка сервера');
+            }
+
+            clearError();
+            loadArrays();
+        } catch (error) {
+            console.error('Error:', error);
+            showError(error.message);
+        }
+    }
+
+    // generateArray заполняет поле ввода случайным массивом с сервера (без сохранения)
+    async function generateArray() {
+        try {
+            const params = {
+                size: Number(elements.genSize.value),
+                min: Number(elements.genMin.value),
+                max: Number(elements.genMax.value),
+                distribution: elements.genDistribution.value
+            };
+            const seed = elements.genSeed.value.trim();
+            if (seed && !/^\d+$/.test(seed)) {
+                throw new Error('Seed должен быть неотрицательным целым числом');
+            }
+            // seed - uint64, он может не поместиться в Number, поэтому подставляется в JSON как есть
+            let body = JSON.stringify(params);
+            if (seed) {
+                body = body.slice(0, -1) + `,"seed":${seed}}`;
+            }
+
+            const response = await fetch('/arrays/generate', {
+                method: 'POST',
+                headers: requestHeaders(),
+                body: body
+            });
+
+            const text = await response.text();
+            const data = JSON.parse(text);
+            if (!response.ok) {
+                throw new Error(data.message || 'Ошибка сервера');
+            }
+
+            setEditing(null);
+            elements.arrayInput.value = data.data.array.join(', ');
+            // seed может не поместиться в Number, поэтому берется из текста ответа
+            const match = text.match(/"seed":(\d+)/);
+            elements.genInfo.textContent = match ? `Seed: ${match[1]}` : '';
+            clearError();
+        } catch (error) {
+            console.error('Error:', error);
+            showError(error.message);
+        }
+    }
+
     function clearInput() {
+        setEditing(null);
         elements.arrayInput.value = '';
-        elements.resultContainer.innerHTML = '';
+        elements.resultContainer.replaceChildren();
+        elements.genInfo.textContent = '';
         clearError();
     }
 
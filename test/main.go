@@ -22,6 +22,7 @@ func main() {
 		fmt.Println("2. Тесты вставки")
 		fmt.Println("3. Тесты сортировки")
 		fmt.Println("4. Тесты очистки")
+		fmt.Println("5. Тест защиты от XSS (нужен запущенный сервер)")
 		fmt.Println("0. Выход")
 
 		var choice int
@@ -41,6 +42,8 @@ func main() {
 			runSortTests(db)
 		case 4:
 			runClearTests(db)
+		case 5:
+			runXSSTest(db)
 		case 0:
 			fmt.Println("Выход из программы")
 			return
@@ -44,9 +44,20 @@ func IntArrayToString(arr []int) string {
 	return sb.String()
 }
 
+// ClearDatabase удаляет все массивы вместе с историей версий и индексом элементов. На arrays ссылаются
+// внешние ключи, поэтому TRUNCATE для нее недоступен: строки удаляются, а счетчик ID сбрасывается
 func ClearDatabase(db *sql.DB) error {
-	_, err := db.Exec("TRUNCATE TABLE arrays")
-	return err
+	for _, stmt := range []string{
+		"TRUNCATE TABLE array_versions",
+		"TRUNCATE TABLE array_elements",
+		"DELETE FROM arrays",
+		"ALTER TABLE arrays AUTO_INCREMENT = 1",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func ParseArrayString(input string) ([]int, error) {
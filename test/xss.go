@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"strings"
+)
+
+// xssPayloads - строки, которые можно записать в array_data только в обход API
+var xssPayloads = []string{
+	`<script>alert(1)</script>`,
+	`1,2,<img src=x onerror=alert(1)>`,
+	`3"><svg onload=alert(1)>`,
+	`javascript:alert(1)`,
+}
+
+// forbiddenMarkers не должны встречаться в ответах сервера, даже экранированными в JSON
+var forbiddenMarkers = []string{"<script", "<img", "<svg", "onerror", "onload", "javascript:"}
+
+// runXSSTest вставляет вредоносные строки напрямую в таблицу arrays и проверяет,
+// что сервер отдает элементы массивов только числами и не возвращает содержимое этих строк.
+// Нужен запущенный сервер (RPS_TEST_URL, по умолчанию http://localhost:8080) и администратор
+// RPS_ADMIN_USERNAME / RPS_ADMIN_PASSWORD - он видит строки без владельца
+func runXSSTest(db *sql.DB) {
+	fmt.Println("\n=== Тест защиты от XSS ===")
+
+	for _, payload := range xssPayloads {
+		if _, err := db.Exec("INSERT INTO arrays (array_data, is_sorted) VALUES (?, ?)", payload, false); err != nil {
+			log.Printf("Ошибка вставки: %v", err)
+			return
+		}
+	}
+	defer func() {
+		args := make([]interface{}, len(xssPayloads))
+		for i, p := range xssPayloads {
+			args[i] = p
+		}
+		query := "DELETE FROM arrays WHERE array_data IN (?" + strings.Repeat(", ?", len(xssPayloads)-1) + ")"
+		if _, err := db.Exec(query, args...); err != nil {
+			log.Printf("Ошибка удаления тестовых строк: %v", err)
+		}
+	}()
+
+	client, baseURL, err := loginAsAdmin()
+	if err != nil {
+		log.Printf("Ошибка входа: %v", err)
+		return
+	}
+
+	success := true
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			success = false
+			fmt.Printf("ОШИБКА: "+format+"\n", args...)
+		}
+	}
+
+	// Список массивов: элементы - только числа, поврежденные строки помечены invalid
+	status, body, err := get(client, baseURL+"/arrays")
+	if err != nil {
+		log.Printf("Ошибка запроса /arrays: %v", err)
+		return
+	}
+	check(status == http.StatusOK, "/arrays вернул статус %d", status)
+	checkMarkers(body, "/arrays", check)
+
+	var list struct {
+		Data []struct {
+			ID      int   `json:"id"`
+			Array   []int `json:"array"` // строка или объект вместо числа - ошибка разбора
+			Invalid bool  `json:"invalid"`
+		} `json:"data"`
+	}
+	err = json.Unmarshal(body, &list)
+	check(err == nil, "элементы массивов в /arrays не являются числами: %v", err)
+
+	var invalidIDs []int
+	for _, arr := range list.Data {
+		if arr.Invalid {
+			check(len(arr.Array) == 0, "поврежденный массив #%d вернулся с элементами", arr.ID)
+			invalidIDs = append(invalidIDs, arr.ID)
+		}
+	}
+	check(len(invalidIDs) >= len(xssPayloads), "помечено поврежденными %d массивов, ожидалось не меньше %d", len(invalidIDs), len(xssPayloads))
+
+	// Загрузка поврежденного массива: 422 без содержимого строки
+	for _, id := range invalidIDs {
+		status, body, err := get(client, fmt.Sprintf("%s/arrays/load?id=%d", baseURL, id))
+		if err != nil {
+			log.Printf("Ошибка запроса /arrays/load: %v", err)
+			return
+		}
+		check(status == http.StatusUnprocessableEntity, "/arrays/load?id=%d вернул статус %d", id, status)
+		checkMarkers(body, fmt.Sprintf("/arrays/load?id=%d", id), check)
+	}
+
+	fmt.Printf("Проверено поврежденных массивов: %d\n", len(invalidIDs))
+	fmt.Printf("Успешно: %v\n", success)
+}
+
+func checkMarkers(body []byte, name string, check func(bool, string, ...interface{})) {
+	lower := strings.ToLower(string(body))
+	for _, marker := range forbiddenMarkers {
+		// json.Marshal экранирует < и > как \u003c и \u003e - проверяем оба варианта
+		escaped := strings.NewReplacer("<", `\u003c`, ">", `\u003e`).Replace(marker)
+		check(!strings.Contains(lower, marker) && !strings.Contains(lower, escaped),
+			"ответ %s содержит %q", name, marker)
+	}
+}
+
+func loginAsAdmin() (*http.Client, string, error) {
+	baseURL := strings.TrimRight(getEnv("RPS_TEST_URL", "http://localhost:8080"), "/")
+	username, password := os.Getenv("RPS_ADMIN_USERNAME"), os.Getenv("RPS_ADMIN_PASSWORD")
+	if username == "" || password == "" {
+		return nil, "", fmt.Errorf("задайте RPS_ADMIN_USERNAME и RPS_ADMIN_PASSWORD")
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, "", err
+	}
+	client := &http.Client{Jar: jar}
+
+	payload, _ := json.Marshal(map[string]string{"username": username, "password": password})
+	resp, err := client.Post(baseURL+"/auth/login", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("/auth/login вернул статус %d", resp.StatusCode)
+	}
+	return client, baseURL, nil
+}
+
+func get(client *http.Client, url string) (int, []byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, body, err
+}
+
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}